@@ -0,0 +1,340 @@
+package xmlproc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// charset describes a character encoding supported by the processor.
+type charset struct {
+	name string
+	bom  []byte
+	// decode reads a single character from the input.
+	decode func(r *bufio.Reader) (rune, error)
+	// encode appends an encoded character to the buffer, it reports false
+	// if the character cannot be represented in the encoding.
+	encode func(buf []byte, r rune) ([]byte, bool)
+}
+
+var (
+	charsetUTF8 = &charset{
+		name: "UTF-8",
+		decode: func(r *bufio.Reader) (rune, error) {
+			c, _, err := r.ReadRune()
+			return c, err
+		},
+		encode: func(buf []byte, r rune) ([]byte, bool) {
+			return append(buf, string(r)...), true
+		},
+	}
+	charsetUTF16LE = &charset{
+		name:   "UTF-16",
+		bom:    []byte{0xff, 0xfe},
+		decode: decodeUTF16(false),
+		encode: encodeUTF16(false),
+	}
+	charsetUTF16BE = &charset{
+		name:   "UTF-16",
+		bom:    []byte{0xfe, 0xff},
+		decode: decodeUTF16(true),
+		encode: encodeUTF16(true),
+	}
+	charsetASCII   = singleByteCharset("US-ASCII", 0x80, nil)
+	charsetLatin1  = singleByteCharset("ISO-8859-1", 0x100, nil)
+	charsetWin1252 = singleByteCharset("windows-1252", 0x100, map[byte]rune{
+		0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„',
+		0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ',
+		0x89: '‰', 0x8a: 'Š', 0x8b: '‹', 0x8c: 'Œ',
+		0x8e: 'Ž', 0x91: '‘', 0x92: '’', 0x93: '“',
+		0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+		0x98: '˜', 0x99: '™', 0x9a: 'š', 0x9b: '›',
+		0x9c: 'œ', 0x9e: 'ž', 0x9f: 'Ÿ',
+	})
+)
+
+// lookupCharset returns a supported charset by its name or alias.
+func lookupCharset(label string) *charset {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "utf-8", "utf8":
+		return charsetUTF8
+	case "utf-16", "utf16", "utf-16le":
+		return charsetUTF16LE
+	case "utf-16be":
+		return charsetUTF16BE
+	case "us-ascii", "ascii":
+		return charsetASCII
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1":
+		return charsetLatin1
+	case "windows-1252", "cp1252", "x-cp1252":
+		return charsetWin1252
+	default:
+		return nil
+	}
+}
+
+// singleByteCharset creates a charset mapping bytes below the limit to
+// the same code points, except for the ones listed in the table.
+func singleByteCharset(name string, limit int, table map[byte]rune) *charset {
+	reverse := map[rune]byte{}
+	for b, r := range table {
+		reverse[r] = b
+	}
+
+	return &charset{
+		name: name,
+		decode: func(r *bufio.Reader) (rune, error) {
+			b, err := r.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			if c, ok := table[b]; ok {
+				return c, nil
+			}
+			if int(b) >= limit {
+				return utf8.RuneError, nil
+			}
+			return rune(b), nil
+		},
+		encode: func(buf []byte, r rune) ([]byte, bool) {
+			if b, ok := reverse[r]; ok {
+				return append(buf, b), true
+			}
+			if _, ok := table[byte(r)]; ok || int(r) >= limit {
+				return buf, false
+			}
+			return append(buf, byte(r)), true
+		},
+	}
+}
+
+func decodeUTF16(bigEndian bool) func(r *bufio.Reader) (rune, error) {
+	unit := func(r *bufio.Reader) (rune, error) {
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		if bigEndian {
+			return rune(b[0])<<8 | rune(b[1]), nil
+		}
+		return rune(b[1])<<8 | rune(b[0]), nil
+	}
+
+	return func(r *bufio.Reader) (rune, error) {
+		c, err := unit(r)
+		if err != nil || !utf16.IsSurrogate(c) {
+			return c, err
+		}
+		c2, err := unit(r)
+		if err != nil {
+			return 0, err
+		}
+		return utf16.DecodeRune(c, c2), nil
+	}
+}
+
+func encodeUTF16(bigEndian bool) func(buf []byte, r rune) ([]byte, bool) {
+	unit := func(buf []byte, c rune) []byte {
+		if bigEndian {
+			return append(buf, byte(c>>8), byte(c))
+		}
+		return append(buf, byte(c), byte(c>>8))
+	}
+
+	return func(buf []byte, r rune) ([]byte, bool) {
+		if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+			return unit(unit(buf, r1), r2), true
+		}
+		return unit(buf, r), true
+	}
+}
+
+var declEncoding = regexp.MustCompile(`^<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._-]+)["']`)
+
+// detectCharset detects the encoding of a document by its byte order mark
+// or XML declaration. It returns a reader of the document converted to
+// UTF-8, and the detected charset if a conversion was needed.
+// Documents in unsupported encodings are returned as is.
+func detectCharset(r io.Reader) (io.Reader, *charset) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(1024)
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0xef, 0xbb, 0xbf}):
+		br.Discard(3)
+		return br, nil
+	case bytes.HasPrefix(head, charsetUTF16LE.bom):
+		br.Discard(2)
+		return &charsetReader{r: br, cs: charsetUTF16LE}, charsetUTF16LE
+	case bytes.HasPrefix(head, charsetUTF16BE.bom):
+		br.Discard(2)
+		return &charsetReader{r: br, cs: charsetUTF16BE}, charsetUTF16BE
+	case bytes.HasPrefix(head, []byte{'<', 0, '?', 0}):
+		return &charsetReader{r: br, cs: charsetUTF16LE}, charsetUTF16LE
+	case bytes.HasPrefix(head, []byte{0, '<', 0, '?'}):
+		return &charsetReader{r: br, cs: charsetUTF16BE}, charsetUTF16BE
+	}
+
+	m := declEncoding.FindSubmatch(head)
+	if m == nil {
+		return br, nil
+	}
+	cs := lookupCharset(string(m[1]))
+	if cs == nil || cs == charsetUTF8 || cs == charsetUTF16LE || cs == charsetUTF16BE {
+		return br, nil
+	}
+	return &charsetReader{r: br, cs: cs}, cs
+}
+
+// charsetReader converts the input to UTF-8.
+type charsetReader struct {
+	r   *bufio.Reader
+	cs  *charset
+	buf []byte
+}
+
+func (r *charsetReader) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) {
+		c, err := r.cs.decode(r.r)
+		if err != nil {
+			if len(r.buf) > 0 {
+				break
+			}
+			return 0, err
+		}
+		r.buf = append(r.buf, string(c)...)
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// charsetWriter converts the UTF-8 output to the given charset.
+// Characters missing in the charset are written as character references,
+// charsetChecker makes sure they're found only in the character data and
+// attribute values.
+type charsetWriter struct {
+	w       io.Writer
+	cs      *charset
+	partial []byte
+	started bool
+}
+
+func newCharsetWriter(w io.Writer, cs *charset) *charsetWriter {
+	return &charsetWriter{w: w, cs: cs}
+}
+
+func (w *charsetWriter) Write(p []byte) (int, error) {
+	var out []byte
+	if !w.started {
+		out = append(out, w.cs.bom...)
+		w.started = true
+	}
+
+	data := append(w.partial, p...)
+	for len(data) > 0 {
+		c, size := utf8.DecodeRune(data)
+		if c == utf8.RuneError && !utf8.FullRune(data) {
+			break
+		}
+		data = data[size:]
+
+		var ok bool
+		if out, ok = w.cs.encode(out, c); !ok {
+			for _, b := range []byte(fmt.Sprintf("&#x%X;", c)) {
+				out, _ = w.cs.encode(out, rune(b))
+			}
+		}
+	}
+	w.partial = append([]byte(nil), data...)
+
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// charsetChecker reports the characters missing in the output charset
+// where they cannot be written as character references, i.e. in names,
+// comments, processing instructions and directives.
+type charsetChecker struct {
+	cs *charset
+}
+
+func (m charsetChecker) Map(t xml.Token) (xml.Token, error) {
+	var err error
+	switch token := t.(type) {
+	case xml.StartElement:
+		err = m.check("name", token.Name.Local)
+		for _, a := range token.Attr {
+			if err == nil {
+				err = m.check("name", a.Name.Local)
+			}
+		}
+	case xml.EndElement:
+		err = m.check("name", token.Name.Local)
+	case xml.Comment:
+		err = m.check("comment", string(token))
+	case xml.ProcInst:
+		err = m.check("processing instruction", token.Target+string(token.Inst))
+	case xml.Directive:
+		err = m.check("directive", string(token))
+	}
+	return t, err
+}
+
+func (m charsetChecker) check(what, s string) error {
+	for _, c := range s {
+		if _, ok := m.cs.encode(nil, c); !ok {
+			return fmt.Errorf("%s: %q cannot be represented in %s", what, c, m.cs.name)
+		}
+	}
+	return nil
+}
+
+// declarationMapper makes the XML declaration of a document state the given
+// encoding. If a document has no declaration, it's added unless the encoding
+// is UTF-8.
+type declarationMapper struct {
+	encoding string
+	started  bool
+}
+
+var declEncodingAttr = regexp.MustCompile(`(\sencoding\s*=\s*)["'][^"']*["']`)
+
+func (m *declarationMapper) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapTokens(t))
+}
+
+func (m *declarationMapper) MapTokens(t xml.Token) ([]xml.Token, error) {
+	if m.started {
+		return []xml.Token{t}, nil
+	}
+	m.started = true
+
+	if pi, ok := t.(xml.ProcInst); ok && pi.Target == "xml" {
+		inst := string(pi.Inst)
+		if declEncodingAttr.MatchString(inst) {
+			inst = declEncodingAttr.ReplaceAllString(inst, `${1}"`+m.encoding+`"`)
+		} else if m.encoding != charsetUTF8.name {
+			inst += ` encoding="` + m.encoding + `"`
+		}
+		return []xml.Token{xml.ProcInst{Target: "xml", Inst: []byte(inst)}}, nil
+	}
+
+	if m.encoding == charsetUTF8.name {
+		return []xml.Token{t}, nil
+	}
+	decl := xml.ProcInst{
+		Target: "xml",
+		Inst:   []byte(`version="1.0" encoding="` + m.encoding + `"`),
+	}
+	return []xml.Token{decl, t}, nil
+}
@@ -0,0 +1,120 @@
+package xmlproc
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf16"
+
+	. "github.com/onsi/gomega"
+)
+
+func utf16le(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+func utf16be(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return b
+}
+
+func processBytes(p Processor, src []byte) []byte {
+	dst := &bytes.Buffer{}
+	err := p.ProcessStreams(dst, bytes.NewReader(src))
+	Ω(err).ShouldNot(HaveOccurred())
+	return dst.Bytes()
+}
+
+func TestCharsetInputWindows1252(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?><a x=\"\x80\">caf\xe9 \x93q\x94</a>")
+	p := Processor{Format: Format{Style: FormatPreserve}}
+	Ω(string(processBytes(p, src))).Should(Equal(
+		`<?xml version="1.0" encoding="UTF-8"?><a x="€">café “q”</a>`))
+
+	p.Lossless = true
+	Ω(string(processBytes(p, src))).Should(Equal(
+		`<?xml version="1.0" encoding="UTF-8"?><a x="€">café “q”</a>`))
+}
+
+func TestCharsetInputUTF16(t *testing.T) {
+	RegisterTestingT(t)
+
+	doc := `<?xml version="1.0" encoding="UTF-16"?><a>𝄞 ж</a>`
+	p := Processor{Format: Format{Style: FormatPreserve}}
+	expected := `<?xml version="1.0" encoding="UTF-8"?><a>𝄞 ж</a>`
+
+	Ω(string(processBytes(p, append([]byte{0xff, 0xfe}, utf16le(doc)...)))).Should(Equal(expected))
+	Ω(string(processBytes(p, append([]byte{0xfe, 0xff}, utf16be(doc)...)))).Should(Equal(expected))
+	Ω(string(processBytes(p, utf16le(doc)))).Should(Equal(expected))
+	Ω(string(processBytes(p, utf16be(doc)))).Should(Equal(expected))
+}
+
+func TestCharsetInputUTF8BOM(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := []byte("\xef\xbb\xbf<a>ж</a>")
+	p := Processor{Format: Format{Style: FormatPreserve}}
+	Ω(string(processBytes(p, src))).Should(Equal("<a>ж</a>"))
+}
+
+func TestCharsetOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := []byte(`<?xml version="1.0"?><a x="é">€ ж</a>`)
+
+	p := Processor{Format: Format{Style: FormatPreserve}, Encoding: "ISO-8859-1"}
+	Ω(processBytes(p, src)).Should(Equal([]byte(
+		"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a x=\"\xe9\">&#x20AC; &#x436;</a>")))
+
+	p.Encoding = "cp1252"
+	Ω(processBytes(p, src)).Should(Equal([]byte(
+		"<?xml version=\"1.0\" encoding=\"windows-1252\"?><a x=\"\xe9\">\x80 &#x436;</a>")))
+
+	p.Encoding = "utf-16be"
+	Ω(processBytes(p, []byte(`<a>𝄞</a>`))).Should(Equal(append([]byte{0xfe, 0xff},
+		utf16be(`<?xml version="1.0" encoding="UTF-16"?><a>𝄞</a>`)...)))
+
+	p.Encoding = "ebcdic"
+	err := p.ProcessStreams(&bytes.Buffer{}, bytes.NewReader(src))
+	Ω(err).Should(MatchError("xmlproc: unsupported encoding: ebcdic"))
+}
+
+func TestCharsetOutputUnrepresentable(t *testing.T) {
+	RegisterTestingT(t)
+
+	p := Processor{Encoding: "US-ASCII"}
+	err := p.ProcessStreams(&bytes.Buffer{}, bytes.NewBufferString(`<café>x</café>`))
+	Ω(err).Should(MatchError(ContainSubstring(`name: 'é' cannot be represented in US-ASCII`)))
+
+	p.Lossless = true
+	err = p.ProcessStreams(&bytes.Buffer{}, bytes.NewBufferString(`<a><!-- é --></a>`))
+	Ω(err).Should(MatchError(ContainSubstring(`comment: 'é' cannot be represented in US-ASCII`)))
+
+	Ω(processBytes(p, []byte(`<a x="é">é</a>`))).Should(Equal([]byte(
+		`<?xml version="1.0" encoding="US-ASCII"?><a x="&#xE9;">&#xE9;</a>`)))
+}
+
+func TestCharsetRoundTrip(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?><a>caf\xe9 \x80</a>")
+	p := Processor{Format: Format{Style: FormatPreserve}, Encoding: "windows-1252", Lossless: true}
+	Ω(processBytes(p, src)).Should(Equal(src))
+}
+
+func TestCharsetUnsupportedInput(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<?xml version="1.0" encoding="koi8-r"?><a/>`
+	err := Processor{}.ProcessStreams(&bytes.Buffer{}, bytes.NewBufferString(src))
+	Ω(err).Should(HaveOccurred())
+	Ω(err.Error()).Should(ContainSubstring("unsupported encoding: koi8-r"))
+}
@@ -0,0 +1,282 @@
+// Command xmlproc applies the built-in mappers to XML documents.
+//
+// Usage:
+//
+//	xmlproc [flags] [file ...]
+//
+// Documents are read from the given files, or from the standard input if
+// no files are given, and written to the standard output. With -i flag the
+// files are modified in place.
+//
+// Elements are selected by expressions of the selector package, e.g.
+// /stix:STIX_Package/stix:Indicators/stix:Indicator.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PlanitarInc/go-xmlproc"
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	"github.com/PlanitarInc/go-xmlproc/rules"
+)
+
+// listFlag is a flag which may be given multiple times.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *listFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+type options struct {
+	prune       bool
+	normalizeNS bool
+	log         bool
+	logFormat   string
+	lossless    bool
+	format      string
+	rules       string
+	ruleList    []*rules.Rule
+	inPlace     bool
+	backup      string
+	rename      listFlag
+	setAttr     listFlag
+	delete      listFlag
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := options{}
+	fs := flag.NewFlagSet("xmlproc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&opts.prune, "prune", false, "remove comments and whitespace-only text")
+	fs.BoolVar(&opts.normalizeNS, "normalize-ns", true, "normalize namespace prefixes")
+	fs.BoolVar(&opts.log, "log", false, "log processed tokens to the standard error")
+	fs.StringVar(&opts.logFormat, "log-format", "text", "log format: text or json")
+	fs.BoolVar(&opts.lossless, "lossless", false, "keep the original text of untouched tokens")
+	fs.StringVar(&opts.format, "format", "default", "output format: default, indent, preserve or minify")
+	fs.BoolVar(&opts.inPlace, "i", false, "modify files in place")
+	fs.StringVar(&opts.backup, "backup", "", "backup suffix for files modified in place")
+	fs.StringVar(&opts.rules, "rules", "", "apply the rules of a rule `file` before other edits")
+	fs.Var(&opts.rename, "rename", "rename elements, `selector=name`")
+	fs.Var(&opts.setAttr, "set-attr", "set an attribute, `selector/@attr=value`")
+	fs.Var(&opts.delete, "delete", "delete elements with their content, `selector`")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: xmlproc [flags] [file ...]\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if err := loadRules(&opts, explicit); err != nil {
+		fmt.Fprintf(stderr, "xmlproc: %v\n", err)
+		return 2
+	}
+
+	if _, err := newProcessor(opts, stderr); err != nil {
+		fmt.Fprintf(stderr, "xmlproc: %v\n", err)
+		return 2
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		if opts.inPlace {
+			fmt.Fprintf(stderr, "xmlproc: -i requires files\n")
+			return 2
+		}
+		files = []string{"-"}
+	}
+
+	status := 0
+	for _, name := range files {
+		// The mappers keep the state of a document, so a document failed
+		// halfway must not affect the next one.
+		p, err := newProcessor(opts, stderr)
+		if err == nil {
+			err = processFile(p, opts, name, stdin, stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "xmlproc: %s: %v\n", name, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// loadRules reads the rule file, if any, and applies its settings to
+// the options. The explicitly set flags must not contradict the file.
+func loadRules(opts *options, explicit map[string]bool) error {
+	if opts.rules == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(opts.rules)
+	if err != nil {
+		return err
+	}
+	f, err := rules.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	opts.ruleList = f.Rules
+	opts.prune = opts.prune || f.Prune
+	if !f.NormalizeNS {
+		if explicit["normalize-ns"] && opts.normalizeNS {
+			return fmt.Errorf("-normalize-ns conflicts with normalize-ns: false of %s", opts.rules)
+		}
+		opts.normalizeNS = false
+	}
+	return nil
+}
+
+// newProcessor builds the mapper chain selected by the flags.
+// Element edits come first, followed by pruning, namespace normalization
+// and logging.
+func newProcessor(opts options, stderr io.Writer) (*xmlproc.Processor, error) {
+	p := xmlproc.NewProcessor(xmlproc.WithLossless(opts.lossless))
+
+	switch opts.format {
+	case "default":
+	case "indent":
+		p.Format = xmlproc.Format{Style: xmlproc.FormatIndent, Indent: "  "}
+	case "preserve":
+		p.Format = xmlproc.Format{Style: xmlproc.FormatPreserve}
+	case "minify":
+		p.Format = xmlproc.Format{Style: xmlproc.FormatMinify}
+	default:
+		return nil, fmt.Errorf("unknown format: %s", opts.format)
+	}
+
+	for _, r := range opts.ruleList {
+		p.AddMapper(r.Mapper())
+	}
+
+	var rr []*rules.Rule
+	for _, v := range opts.delete {
+		rr = append(rr, &rules.Rule{Select: v, Delete: true})
+	}
+
+	for _, v := range opts.rename {
+		i := strings.LastIndex(v, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid -rename %q, expected selector=name", v)
+		}
+		rr = append(rr, &rules.Rule{Select: v[:i], Rename: v[i+1:]})
+	}
+
+	for _, v := range opts.setAttr {
+		i := strings.LastIndex(v, "/@")
+		if i < 0 || !strings.Contains(v[i:], "=") {
+			return nil, fmt.Errorf("invalid -set-attr %q, expected selector/@attr=value", v)
+		}
+		attr := strings.SplitN(v[i+2:], "=", 2)
+		rr = append(rr, &rules.Rule{
+			Select:  v[:i],
+			SetAttr: &rules.SetAttr{Name: attr[0], Value: attr[1]},
+		})
+	}
+
+	for _, r := range rr {
+		if err := r.Compile(nil); err != nil {
+			return nil, err
+		}
+		p.AddMapper(r.Mapper())
+	}
+
+	if opts.prune {
+		p.AddMapper(&mappers.Pruner{})
+	} else if opts.format == "default" && !opts.lossless {
+		// The default format indents the elements, the whitespace of
+		// the document would add up to the indentation.
+		p.AddMapper(&mappers.Pruner{Kinds: mappers.PruneWhitespace})
+	}
+	if opts.normalizeNS {
+		p.AddMapper(&mappers.NSNormalizer{})
+	}
+	if opts.log {
+		l := &mappers.Logger{Writer: stderr}
+		switch opts.logFormat {
+		case "text":
+		case "json":
+			l.Format = mappers.LogJSON
+		default:
+			return nil, fmt.Errorf("unknown log format %q", opts.logFormat)
+		}
+		p.AddMapper(l)
+	}
+	return p, nil
+}
+
+// processFile processes a single file, "-" stands for the standard input.
+func processFile(p *xmlproc.Processor, opts options, name string, stdin io.Reader, stdout io.Writer) error {
+	if name == "-" {
+		return p.ProcessStreams(stdout, stdin)
+	}
+
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if !opts.inPlace {
+		return p.ProcessStreams(stdout, bytes.NewReader(src))
+	}
+
+	dst := &bytes.Buffer{}
+	if err := p.ProcessStreams(dst, bytes.NewReader(src)); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if opts.backup != "" {
+		if err := ioutil.WriteFile(name+opts.backup, src, info.Mode()); err != nil {
+			return err
+		}
+	}
+	return replaceFile(name, dst.Bytes(), info.Mode())
+}
+
+// replaceFile writes the data to a temporary file next to the named one,
+// and renames it over the named file, so the original is kept intact if
+// the writing fails.
+func replaceFile(name string, data []byte, mode os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
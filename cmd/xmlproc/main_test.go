@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const doc = `<r:root xmlns:r="urn:r">
+  <!-- comment -->
+  <r:item id="1">one</r:item>
+  <r:item id="2">two</r:item>
+</r:root>`
+
+func runString(args []string, stdin string) (int, string, string) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	status := run(args, bytes.NewBufferString(stdin), stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRunStdin(t *testing.T) {
+	RegisterTestingT(t)
+
+	status, out, _ := runString([]string{
+		"-prune",
+		"-delete", "/r:root/r:item[@id='1']",
+		"-rename", "r:item=r:entry",
+		"-set-attr", "/r:root/r:item/@id=x=1",
+		"-set-attr", "/r:root/@version=2",
+	}, doc)
+	Ω(status).Should(Equal(0))
+	Ω(out).Should(Equal(`<r:root xmlns:r="urn:r" version="2">
+  <r:entry id="x=1">two</r:entry>
+</r:root>`))
+}
+
+func TestRunFormat(t *testing.T) {
+	RegisterTestingT(t)
+
+	status, out, _ := runString([]string{"-format", "minify"}, doc)
+	Ω(status).Should(Equal(0))
+	Ω(out).Should(Equal(`<r:root xmlns:r="urn:r"><!-- comment --><r:item id="1">one</r:item><r:item id="2">two</r:item></r:root>`))
+
+	status, out, _ = runString([]string{"-lossless", "-set-attr", "r:item[2]/@id=3"}, doc)
+	Ω(status).Should(Equal(0))
+	Ω(out).Should(Equal(`<r:root xmlns:r="urn:r">
+  <!-- comment -->
+  <r:item id="1">one</r:item>
+  <r:item id="3">two</r:item>
+</r:root>`))
+}
+
+func TestRunLog(t *testing.T) {
+	RegisterTestingT(t)
+
+	status, _, errOut := runString([]string{"-log"}, "<a/>")
+	Ω(status).Should(Equal(0))
+	Ω(errOut).Should(Equal("0 /a <a>\n4 /a </a>\n"))
+
+	status, _, errOut = runString([]string{"-log", "-log-format", "json"}, "<a/>")
+	Ω(status).Should(Equal(0))
+	Ω(errOut).Should(HavePrefix(`{"offset":0,"line":1,"column":1,"path":"/a","kind":"start","token":"<a>"}`))
+}
+
+func TestRunDefaultFormat(t *testing.T) {
+	RegisterTestingT(t)
+
+	status, out, _ := runString(nil, "<a>\n  <b x=\"1\"/>\n  <c>text</c>\n</a>")
+	Ω(status).Should(Equal(0))
+	Ω(out).Should(Equal("<a>\n  <b x=\"1\"></b>\n  <c>text</c>\n</a>"))
+}
+
+func TestRunErrors(t *testing.T) {
+	RegisterTestingT(t)
+
+	status, _, _ := runString([]string{"-unknown"}, doc)
+	Ω(status).Should(Equal(2))
+
+	status, _, errOut := runString([]string{"-rename", "a"}, doc)
+	Ω(status).Should(Equal(2))
+	Ω(errOut).Should(ContainSubstring("invalid -rename"))
+
+	status, _, errOut = runString([]string{"-set-attr", "a/b"}, doc)
+	Ω(status).Should(Equal(2))
+	Ω(errOut).Should(ContainSubstring("invalid -set-attr"))
+
+	status, _, _ = runString([]string{"-delete", "a["}, doc)
+	Ω(status).Should(Equal(2))
+
+	status, _, _ = runString([]string{"-format", "pretty"}, doc)
+	Ω(status).Should(Equal(2))
+
+	status, _, _ = runString([]string{"-i"}, doc)
+	Ω(status).Should(Equal(2))
+
+	status, _, errOut = runString(nil, "<a>")
+	Ω(status).Should(Equal(1))
+	Ω(errOut).Should(HavePrefix("xmlproc: -: "))
+
+	status, _, _ = runString([]string{"does-not-exist.xml"}, "")
+	Ω(status).Should(Equal(1))
+}
+
+func TestRunFailedFileDoesNotAffectNext(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "xmlproc")
+	Ω(err).ShouldNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	bad := filepath.Join(dir, "bad.xml")
+	good := filepath.Join(dir, "good.xml")
+	Ω(ioutil.WriteFile(bad, []byte("<r><d><x></d></r>"), 0640)).ShouldNot(HaveOccurred())
+	Ω(ioutil.WriteFile(good, []byte("<r><d></d><e></e></r>"), 0640)).ShouldNot(HaveOccurred())
+
+	status, out, errOut := runString([]string{"-delete", "//d", bad, good}, "")
+	Ω(status).Should(Equal(1))
+	Ω(errOut).Should(HavePrefix("xmlproc: " + bad + ": "))
+	// The output of the failed document stops at the error.
+	Ω(out).Should(Equal(`<r><r>
+  <e></e>
+</r>`))
+}
+
+func TestRunRules(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "xmlproc")
+	Ω(err).ShouldNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "rules.yaml")
+	Ω(ioutil.WriteFile(name, []byte(`
+rules:
+  - select: /r:root/r:item[2]
+    delete: true
+`), 0640)).ShouldNot(HaveOccurred())
+
+	status, out, _ := runString([]string{"-rules", name, "-prune", "-rename", "r:item=r:entry"}, doc)
+	Ω(status).Should(Equal(0))
+	Ω(out).Should(Equal(`<r:root xmlns:r="urn:r">
+  <r:entry id="1">one</r:entry>
+</r:root>`))
+
+	Ω(ioutil.WriteFile(name, []byte("rules: [{select: a}]"), 0640)).ShouldNot(HaveOccurred())
+	status, _, errOut := runString([]string{"-rules", name}, doc)
+	Ω(status).Should(Equal(2))
+	Ω(errOut).Should(ContainSubstring("line 1: expected exactly one action"))
+
+	Ω(ioutil.WriteFile(name, []byte("prune: true\nnormalize-ns: false\n"), 0640)).ShouldNot(HaveOccurred())
+	status, out, _ = runString([]string{"-rules", name}, doc)
+	Ω(status).Should(Equal(0))
+	Ω(out).ShouldNot(ContainSubstring("comment"))
+
+	status, _, errOut = runString([]string{"-rules", name, "-normalize-ns"}, doc)
+	Ω(status).Should(Equal(2))
+	Ω(errOut).Should(ContainSubstring("-normalize-ns conflicts with normalize-ns: false"))
+}
+
+func TestRunInPlace(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "xmlproc")
+	Ω(err).ShouldNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "doc.xml")
+	Ω(ioutil.WriteFile(name, []byte(doc), 0640)).ShouldNot(HaveOccurred())
+
+	status, out, _ := runString([]string{"-i", "-backup", ".orig", "-prune", "-format", "minify", name}, "")
+	Ω(status).Should(Equal(0))
+	Ω(out).Should(BeEmpty())
+
+	res, err := ioutil.ReadFile(name)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(string(res)).Should(Equal(`<r:root xmlns:r="urn:r"><r:item id="1">one</r:item><r:item id="2">two</r:item></r:root>`))
+
+	backup, err := ioutil.ReadFile(name + ".orig")
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(string(backup)).Should(Equal(doc))
+
+	files, err := ioutil.ReadDir(dir)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(files).Should(HaveLen(2))
+}
@@ -0,0 +1,43 @@
+package xmlproc
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// ProcessError is returned by the processor when a document cannot be
+// processed. It describes the position of the failed token in the input
+// document and the failed mapper, if any.
+type ProcessError struct {
+	// Offset, Line and Column point at the beginning of the failed token
+	// in the input document. Line and column numbers start at 1.
+	Offset int64
+	Line   int
+	Column int
+	// Path is the element path of the failed token, see mappers.Path.
+	Path string
+	// Token is the token being processed, as it was given to the mapper.
+	Token xml.Token
+	// MapperIndex is the index of the failed mapper in the processor's
+	// mapper list, or -1 if the error comes from decoding or encoding.
+	MapperIndex int
+	Mapper      Mapper
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ProcessError) Error() string {
+	where := fmt.Sprintf("line %d, column %d", e.Line, e.Column)
+	if e.Path != "" {
+		where += " " + e.Path
+	}
+	if e.MapperIndex < 0 {
+		return fmt.Sprintf("xmlproc: %s: %v", where, e.Err)
+	}
+	return fmt.Sprintf("xmlproc: %s: mapper #%d (%T): %v",
+		where, e.MapperIndex, e.Mapper, e.Err)
+}
+
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
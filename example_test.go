@@ -3,6 +3,7 @@ package xmlproc
 import (
 	"bytes"
 	"encoding/xml"
+	"fmt"
 	"os"
 )
 
@@ -107,3 +108,108 @@ version="1.0.1">
 	//   </stix:Indicators>
 	// </stix:STIX_Package>
 }
+
+// annotator is a multi-token mapper adding a comment before every element.
+type annotator struct{}
+
+func (m annotator) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapTokens(t))
+}
+
+func (m annotator) MapTokens(t xml.Token) ([]xml.Token, error) {
+	if start, ok := t.(xml.StartElement); ok {
+		comment := xml.Comment(" " + start.Name.Local + " ")
+		return []xml.Token{comment, t}, nil
+	}
+	return []xml.Token{t}, nil
+}
+
+func ExampleMultiMapper() {
+	example := `<a><b>text</b><c/></a>`
+
+	src := bytes.NewBufferString(example)
+	dec := xml.NewDecoder(src)
+
+	enc := xml.NewEncoder(os.Stdout)
+	defer enc.Flush()
+
+	p := Processor{
+		Mappers: []Mapper{
+			annotator{},
+		},
+	}
+	p.Process(enc, dec)
+	// Output:
+	// <!-- a --><a><!-- b --><b>text</b><!-- c --><c></c></a>
+}
+
+// indexer is a context-aware mapper adding the element path to every
+// second-level element.
+type indexer struct{}
+
+func (m indexer) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapContext(&Context{}, t))
+}
+
+func (m indexer) MapContext(c *Context, t xml.Token) ([]xml.Token, error) {
+	if start, ok := t.(xml.StartElement); ok && c.Path.Depth() == 2 {
+		start.Attr = append(start.Attr, xml.Attr{
+			Name:  xml.Name{Local: "path"},
+			Value: fmt.Sprintf("%s[%d]", c.Path, c.Path.Top().NameIndex),
+		})
+		return []xml.Token{start}, nil
+	}
+	return []xml.Token{t}, nil
+}
+
+func ExampleContextMapper() {
+	example := `<a><b/><c><b/></c><b/></a>`
+
+	src := bytes.NewBufferString(example)
+	dec := xml.NewDecoder(src)
+
+	enc := xml.NewEncoder(os.Stdout)
+	defer enc.Flush()
+
+	p := Processor{
+		Mappers: []Mapper{
+			indexer{},
+		},
+	}
+	p.Process(enc, dec)
+	// Output:
+	// <a><b path="/a/b[0]"></b><c path="/a/c[0]"><b></b></c><b path="/a/b[1]"></b></a>
+}
+
+func ExampleSubtree() {
+	example := `<list><item>b</item><item>drop me</item><item>a</item></list>`
+
+	src := bytes.NewBufferString(example)
+	dec := xml.NewDecoder(src)
+
+	enc := xml.NewEncoder(os.Stdout)
+	defer enc.Flush()
+
+	p := Processor{
+		Mappers: []Mapper{
+			&Subtree{
+				Match: func(c *Context, t xml.StartElement) bool {
+					return t.Name.Local == "list"
+				},
+				Func: func(c *Context, n *Node) (*Node, error) {
+					var items []*Node
+					for _, item := range n.Elements() {
+						if item.Text() != "drop me" {
+							items = append([]*Node{item}, items...)
+						}
+					}
+					n.Children = items
+					return n, nil
+				},
+			},
+		},
+	}
+	p.Process(enc, dec)
+	// Output:
+	// <list><item>a</item><item>b</item></list>
+}
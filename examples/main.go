@@ -17,16 +17,25 @@ const example = `<taxii_11:Discovery_Response
   in_response_to="1">
 </taxii_11:Discovery_Response>`
 
+const taxii11 = "http://taxii.mitre.org/messages/taxii_xml_binding-1.1"
+
+// IncInResponseTo changes in_response_to attribute of the root
+// Discovery_Response element.
 type IncInResponseTo struct{}
 
 func (m IncInResponseTo) Map(t xml.Token) (xml.Token, error) {
+	return xmlproc.Single(m.MapContext(&xmlproc.Context{}, t))
+}
+
+func (m IncInResponseTo) MapContext(c *xmlproc.Context, t xml.Token) ([]xml.Token, error) {
 	switch token := t.(type) {
 	default:
-		return t, nil
+		return []xml.Token{t}, nil
 
 	case xml.StartElement:
-		if token.Name.Local != "Discovery_Response" {
-			return t, nil
+		root := xml.Name{Space: taxii11, Local: "Discovery_Response"}
+		if c.Path.Depth() != 1 || c.Path.Top().Name != root {
+			return []xml.Token{t}, nil
 		}
 		for i := range token.Attr {
 			if token.Attr[i].Name.Local == "in_response_to" {
@@ -34,7 +43,7 @@ func (m IncInResponseTo) Map(t xml.Token) (xml.Token, error) {
 				break
 			}
 		}
-		return t, nil
+		return []xml.Token{t}, nil
 	}
 }
 
@@ -0,0 +1,351 @@
+package xmlproc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+)
+
+// FormatStyle defines how whitespace is laid out in an output document.
+type FormatStyle int
+
+const (
+	// FormatDefault indents elements with two spaces, keeping the character
+	// data as is. Whitespace-only character data is expected to be removed
+	// by a mapper, e.g. mappers.Pruner.
+	FormatDefault FormatStyle = iota
+	// FormatIndent drops whitespace-only character data and indents
+	// elements using Format.Prefix and Format.Indent.
+	FormatIndent
+	// FormatPreserve keeps the whitespace of a document as is and
+	// doesn't add any indentation.
+	FormatPreserve
+	// FormatMinify drops whitespace-only character data and doesn't add
+	// any indentation.
+	FormatMinify
+)
+
+// Format describes the layout of the documents written by ProcessStreams.
+// The zero value stands for FormatDefault style.
+type Format struct {
+	Style FormatStyle
+	// Prefix and Indent are used by FormatIndent style, similarly to
+	// xml.Encoder.Indent.
+	Prefix string
+	Indent string
+	// AttrPerLine puts every attribute of an element on a separate line.
+	AttrPerLine bool
+	// MaxWidth, if positive, wraps attributes of start tags that would
+	// otherwise exceed the given line width. Character data is never wrapped.
+	MaxWidth int
+}
+
+// tokenWriter is the output of the processing loop, implemented by
+// xml.Encoder and formatter.
+type tokenWriter interface {
+	EncodeToken(xml.Token) error
+	Flush() error
+}
+
+// writer creates the token writer for the format.
+func (f Format) writer(w io.Writer) tokenWriter {
+	prefix, indent := f.indentation()
+	if f.AttrPerLine || f.MaxWidth > 0 {
+		return &formatter{
+			w:      bufio.NewWriter(w),
+			format: f,
+			prefix: prefix,
+			indent: indent,
+		}
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent(prefix, indent)
+	return e
+}
+
+func (f Format) indentation() (prefix, indent string) {
+	switch f.Style {
+	case FormatDefault:
+		return "", "  "
+	case FormatIndent:
+		return f.Prefix, f.Indent
+	default:
+		return "", ""
+	}
+}
+
+// mapper returns a mapper applying the format to the processed tokens,
+// or nil if no changes are needed.
+func (f Format) mapper() Mapper {
+	if f.Style == FormatIndent || f.Style == FormatMinify {
+		return whitespaceFilter{}
+	}
+	return nil
+}
+
+// whitespaceFilter drops whitespace-only character data.
+type whitespaceFilter struct{}
+
+func (m whitespaceFilter) Map(t xml.Token) (xml.Token, error) {
+	if data, ok := t.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// formatter is a token writer supporting the attribute layout options.
+// It follows the indentation rules of xml.Encoder. Names may be either
+// normalized, i.e. having the namespace prefix in the local part, or hold
+// namespace URIs; the URIs are written using the prefixes declared in
+// scope, and generated prefixes are declared if needed.
+type formatter struct {
+	w      *bufio.Writer
+	format Format
+	prefix string
+	indent string
+
+	column     int
+	depth      int
+	tags       []openTag
+	ns         mappers.NSStack
+	indentedIn bool
+	putNewline bool
+}
+
+// openTag is an element open in the output.
+type openTag struct {
+	name    xml.Name
+	written string
+}
+
+func (f *formatter) EncodeToken(t xml.Token) error {
+	switch t := t.(type) {
+	case xml.StartElement:
+		if t.Name.Local == "" {
+			return errors.New("xmlproc: start tag with no name")
+		}
+		name, attrs := f.open(t)
+		f.writeIndent(1)
+		f.writeStart(name, attrs)
+
+	case xml.EndElement:
+		if len(f.tags) == 0 || f.tags[len(f.tags)-1].name != t.Name {
+			return errors.New("xmlproc: end tag </" + t.Name.Local + "> does not match start tag")
+		}
+		f.writeIndent(-1)
+		f.write("</" + f.close() + ">")
+
+	case xml.CharData:
+		f.write(escape(string(t), false))
+
+	case xml.Comment:
+		if bytes.Contains(t, []byte("-->")) {
+			return errors.New("xmlproc: comment containing --> marker")
+		}
+		f.write("<!--" + string(t) + "-->")
+
+	case xml.ProcInst:
+		f.write("<?" + t.Target)
+		if len(t.Inst) > 0 {
+			f.write(" " + string(t.Inst))
+		}
+		f.write("?>")
+
+	case xml.Directive:
+		f.write("<!" + string(t) + ">")
+
+	default:
+		return errors.New("xmlproc: invalid token type")
+	}
+	return nil
+}
+
+func (f *formatter) Flush() error {
+	return f.w.Flush()
+}
+
+// open pushes an element onto the stack of open elements, and returns
+// the name and the attributes as written to the output.
+func (f *formatter) open(t xml.StartElement) (string, []string) {
+	f.ns.Push()
+	for _, a := range t.Attr {
+		if prefix, ok := declPrefix(a); ok {
+			f.ns.Set(prefix, a.Value)
+		}
+	}
+
+	var decls []string
+	name := f.qualify(t.Name, false, &decls)
+	var attrs []string
+	for _, a := range t.Attr {
+		if a.Name.Local == "" {
+			continue
+		}
+		var n string
+		if prefix, ok := declPrefix(a); ok {
+			n = "xmlns"
+			if prefix != "" {
+				n += ":" + prefix
+			}
+		} else {
+			n = f.qualify(a.Name, true, &decls)
+		}
+		attrs = append(attrs, n+`="`+escape(a.Value, true)+`"`)
+	}
+
+	f.tags = append(f.tags, openTag{name: t.Name, written: name})
+	return name, append(attrs, decls...)
+}
+
+// close pops the innermost open element, and returns its written name.
+func (f *formatter) close() string {
+	top := f.tags[len(f.tags)-1]
+	f.tags = f.tags[:len(f.tags)-1]
+	if len(f.ns) > 0 {
+		f.ns.Pop()
+	}
+	return top.written
+}
+
+// qualify returns the written form of a name. A namespace URI having no
+// prefix in scope gets a generated one, declared by the appended attribute.
+// Unprefixed attributes are not in the default namespace.
+func (f *formatter) qualify(name xml.Name, attr bool, decls *[]string) string {
+	switch {
+	case name.Space == "":
+		return name.Local
+	case name.Space == "xmlns":
+		return "xmlns:" + name.Local
+	case name.Space == mappers.XMLNamespace:
+		return "xml:" + name.Local
+	}
+
+	if !attr {
+		if p := f.ns.FindPrefix(""); p != nil && p.URI == name.Space {
+			return name.Local
+		}
+	}
+	for _, c := range f.ns {
+		for _, p := range c.Pairs {
+			if p.URI != name.Space || p.Prefix == "" {
+				continue
+			}
+			if bound := f.ns.FindPrefix(p.Prefix); bound.URI == name.Space {
+				return p.Prefix + ":" + name.Local
+			}
+		}
+	}
+
+	prefix := ""
+	for i := 1; prefix == "" || f.ns.FindPrefix(prefix) != nil; i++ {
+		prefix = "ns" + strconv.Itoa(i)
+	}
+	f.ns.Set(prefix, name.Space)
+	*decls = append(*decls, "xmlns:"+prefix+`="`+escape(name.Space, true)+`"`)
+	return prefix + ":" + name.Local
+}
+
+// declPrefix reports whether an attribute is a namespace declaration,
+// in either form, and returns the declared prefix.
+func declPrefix(a xml.Attr) (string, bool) {
+	switch {
+	case a.Name.Space == "xmlns":
+		return a.Name.Local, true
+	case a.Name.Space == "" && a.Name.Local == "xmlns":
+		return "", true
+	case a.Name.Space == "" && strings.HasPrefix(a.Name.Local, "xmlns:"):
+		return a.Name.Local[len("xmlns:"):], true
+	default:
+		return "", false
+	}
+}
+
+func (f *formatter) writeStart(name string, attrs []string) {
+	f.write("<" + name)
+
+	for i, a := range attrs {
+		wrap := f.format.AttrPerLine
+		if f.format.MaxWidth > 0 && i > 0 {
+			width := f.column + 1 + utf8.RuneCountInString(a)
+			if i == len(attrs)-1 {
+				width++ // the closing >
+			}
+			wrap = wrap || width > f.format.MaxWidth
+		}
+
+		if wrap {
+			f.write("\n" + f.prefix + strings.Repeat(f.attrIndent(), f.depth))
+		} else {
+			f.write(" ")
+		}
+		f.write(a)
+	}
+	f.write(">")
+}
+
+// attrIndent returns the indentation unit of wrapped attributes.
+func (f *formatter) attrIndent() string {
+	if f.indent == "" {
+		return "  "
+	}
+	return f.indent
+}
+
+// writeIndent mirrors the indentation logic of xml.Encoder.
+func (f *formatter) writeIndent(depthDelta int) {
+	if f.prefix == "" && f.indent == "" {
+		if depthDelta > 0 {
+			f.depth++
+		} else if depthDelta < 0 {
+			f.depth--
+		}
+		return
+	}
+
+	if depthDelta < 0 {
+		f.depth--
+		if f.indentedIn {
+			f.indentedIn = false
+			return
+		}
+		f.indentedIn = false
+	}
+	if f.putNewline {
+		f.write("\n")
+	} else {
+		f.putNewline = true
+	}
+	f.write(f.prefix + strings.Repeat(f.indent, f.depth))
+	if depthDelta > 0 {
+		f.depth++
+		f.indentedIn = true
+	}
+}
+
+func (f *formatter) write(s string) {
+	f.w.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		f.column = utf8.RuneCountInString(s[i+1:])
+	} else {
+		f.column += utf8.RuneCountInString(s)
+	}
+}
+
+// escape escapes a text the same way xml.Encoder does. Newlines are
+// escaped in attribute values only.
+func escape(s string, attr bool) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	if attr {
+		return b.String()
+	}
+	return strings.Replace(b.String(), "&#xA;", "\n", -1)
+}
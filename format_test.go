@@ -0,0 +1,120 @@
+package xmlproc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	. "github.com/onsi/gomega"
+)
+
+const formatSrc = `<root>
+    <!-- c -->
+    <item id="1" name="first" value="a &amp; b"><sub>text</sub></item>
+    <item id="2"/>
+</root>`
+
+func format(f Format, mm ...Mapper) string {
+	dst := &bytes.Buffer{}
+	p := Processor{Mappers: mm, Format: f}
+	err := p.ProcessStreams(dst, bytes.NewBufferString(formatSrc))
+	Ω(err).ShouldNot(HaveOccurred())
+	return dst.String()
+}
+
+func TestFormatDefault(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(format(Format{}, &mappers.Pruner{})).Should(Equal(`<root>
+  <item id="1" name="first" value="a &amp; b">
+    <sub>text</sub>
+  </item>
+  <item id="2"></item>
+</root>`))
+}
+
+func TestFormatPreserve(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(format(Format{Style: FormatPreserve})).Should(Equal(`<root>
+    <!-- c -->
+    <item id="1" name="first" value="a &amp; b"><sub>text</sub></item>
+    <item id="2"></item>
+</root>`))
+}
+
+func TestFormatMinify(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(format(Format{Style: FormatMinify})).Should(Equal(
+		`<root><!-- c --><item id="1" name="first" value="a &amp; b"><sub>text</sub></item><item id="2"></item></root>`))
+}
+
+func TestFormatIndent(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(format(Format{Style: FormatIndent, Prefix: "#", Indent: "\t"})).Should(Equal(`#<root><!-- c -->
+#	<item id="1" name="first" value="a &amp; b">
+#		<sub>text</sub>
+#	</item>
+#	<item id="2"></item>
+#</root>`))
+}
+
+func TestFormatAttrPerLine(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(format(Format{Style: FormatIndent, Indent: "  ", AttrPerLine: true})).Should(Equal(`<root><!-- c -->
+  <item
+    id="1"
+    name="first"
+    value="a &amp; b">
+    <sub>text</sub>
+  </item>
+  <item
+    id="2"></item>
+</root>`))
+
+	Ω(format(Format{Style: FormatMinify, AttrPerLine: true})).Should(Equal(`<root><!-- c --><item
+    id="1"
+    name="first"
+    value="a &amp; b"><sub>text</sub></item><item
+    id="2"></item></root>`))
+}
+
+func TestFormatMaxWidth(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(format(Format{Style: FormatIndent, Indent: "  ", MaxWidth: 24})).Should(Equal(`<root><!-- c -->
+  <item id="1"
+    name="first"
+    value="a &amp; b">
+    <sub>text</sub>
+  </item>
+  <item id="2"></item>
+</root>`))
+}
+
+func TestFormatNamespaces(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<r xmlns="urn:r" xmlns:p="urn:p" p:x="1"><p:a xml:lang="en"><b></b></p:a></r>`
+	dst := &bytes.Buffer{}
+	p := Processor{
+		Mappers: []Mapper{mappers.SetAttr(xml.Name{Local: "b"}, xml.Name{Space: "urn:q", Local: "y"}, "2")},
+		Format:  Format{AttrPerLine: true},
+	}
+	Ω(p.ProcessStreams(dst, bytes.NewBufferString(src))).ShouldNot(HaveOccurred())
+	Ω(dst.String()).Should(Equal(`<r
+  xmlns="urn:r"
+  xmlns:p="urn:p"
+  p:x="1">
+  <p:a
+    xml:lang="en">
+    <b
+      ns1:y="2"
+      xmlns:ns1="urn:q"></b>
+  </p:a>
+</r>`))
+}
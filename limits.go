@@ -0,0 +1,119 @@
+package xmlproc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Limits restricts the resources a processed document may consume,
+// which is useful when processing untrusted input.
+// A zero value of a limit means no limit.
+//
+// The token limits are checked once the decoder has read a token, i.e.
+// a long attribute value or text is held in memory in full before
+// MaxAttrLen or MaxCharData reports it. Set MaxBytes to bound the memory
+// used for untrusted input.
+type Limits struct {
+	// MaxDepth is the maximum nesting level of elements.
+	MaxDepth int
+	// MaxAttrs is the maximum number of attributes of a single element,
+	// including namespace declarations.
+	MaxAttrs int
+	// MaxAttrLen is the maximum length of a single attribute value,
+	// checked after the whole start tag is read.
+	MaxAttrLen int
+	// MaxCharData is the maximum length of a single character data token,
+	// checked after the whole token is read.
+	MaxCharData int
+	// MaxTokens is the maximum number of tokens in a document.
+	MaxTokens int
+	// MaxBytes is the maximum size of a document.
+	MaxBytes int64
+	// MaxEntityExpansion is the maximum number of bytes a document may grow
+	// by when its entity references are replaced, see xml.Decoder.Entity.
+	MaxEntityExpansion int64
+}
+
+// LimitExceededError is returned when a document exceeds one of
+// the processing limits. The error is wrapped by ProcessError.
+type LimitExceededError struct {
+	// Limit is the name of the exceeded limit, e.g. "MaxDepth".
+	Limit string
+	Max   int64
+	Value int64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("xmlproc: limit %s of %d exceeded: %d", e.Limit, e.Max, e.Value)
+}
+
+// limiter enforces the limits while a document is processed.
+type limiter struct {
+	Limits
+	tokens    int
+	expansion int64
+}
+
+func exceeded(limit string, max, value int64) error {
+	if max > 0 && value > max {
+		return &LimitExceededError{Limit: limit, Max: max, Value: value}
+	}
+	return nil
+}
+
+// check validates a token read by the decoder. The size is the number of
+// input bytes the token was decoded from.
+func (l *limiter) check(c *Context, t xml.Token, size int64) error {
+	l.tokens++
+	if err := exceeded("MaxTokens", int64(l.MaxTokens), int64(l.tokens)); err != nil {
+		return err
+	}
+	if err := exceeded("MaxBytes", l.MaxBytes, c.Offset+size); err != nil {
+		return err
+	}
+
+	var decoded int64
+	switch t := t.(type) {
+	case xml.StartElement:
+		if err := exceeded("MaxDepth", int64(l.MaxDepth), int64(c.Path.Depth())); err != nil {
+			return err
+		}
+		if err := exceeded("MaxAttrs", int64(l.MaxAttrs), int64(len(t.Attr))); err != nil {
+			return err
+		}
+		for _, a := range t.Attr {
+			if err := exceeded("MaxAttrLen", int64(l.MaxAttrLen), int64(len(a.Value))); err != nil {
+				return err
+			}
+			decoded += int64(len(a.Name.Local) + len(a.Value))
+		}
+
+	case xml.CharData:
+		if err := exceeded("MaxCharData", int64(l.MaxCharData), int64(len(t))); err != nil {
+			return err
+		}
+		decoded = int64(len(t))
+	}
+
+	if decoded > size {
+		l.expansion += decoded - size
+	}
+	return exceeded("MaxEntityExpansion", l.MaxEntityExpansion, l.expansion)
+}
+
+// limitReader fails once more than max bytes are read.
+type limitReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	if lerr := exceeded("MaxBytes", r.max, r.read); lerr != nil {
+		return n, lerr
+	}
+	return n, err
+}
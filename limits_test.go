@@ -0,0 +1,71 @@
+package xmlproc
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func limitError(l Limits, src string, opts ...Option) *LimitExceededError {
+	p := NewProcessor(append(opts, WithLimits(l))...)
+	err := p.ProcessStreams(ioutil.Discard, bytes.NewBufferString(src))
+	if err == nil {
+		return nil
+	}
+
+	var le *LimitExceededError
+	Ω(errors.As(err, &le)).Should(BeTrue(), err.Error())
+	return le
+}
+
+func TestLimits(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<a x="12345" y="1"><b><c>text</c></b><d/></a>`
+
+	Ω(limitError(Limits{
+		MaxDepth:    3,
+		MaxAttrs:    2,
+		MaxAttrLen:  5,
+		MaxCharData: 4,
+		MaxTokens:   9,
+		MaxBytes:    int64(len(src)),
+	}, src)).Should(BeNil())
+
+	for _, tc := range []struct {
+		limits Limits
+		err    LimitExceededError
+	}{
+		{Limits{MaxDepth: 2}, LimitExceededError{"MaxDepth", 2, 3}},
+		{Limits{MaxAttrs: 1}, LimitExceededError{"MaxAttrs", 1, 2}},
+		{Limits{MaxAttrLen: 4}, LimitExceededError{"MaxAttrLen", 4, 5}},
+		{Limits{MaxCharData: 3}, LimitExceededError{"MaxCharData", 3, 4}},
+		{Limits{MaxTokens: 8}, LimitExceededError{"MaxTokens", 8, 9}},
+	} {
+		Ω(limitError(tc.limits, src)).Should(Equal(&tc.err))
+	}
+
+	le := limitError(Limits{MaxBytes: 10}, src)
+	Ω(le).ShouldNot(BeNil())
+	Ω(le.Limit).Should(Equal("MaxBytes"))
+	Ω(le.Error()).Should(HavePrefix("xmlproc: limit MaxBytes of 10 exceeded: "))
+}
+
+func TestLimitsEntityExpansion(t *testing.T) {
+	RegisterTestingT(t)
+
+	lol := strings.Repeat("lol", 100)
+	src := `<a x="&lol;">` + strings.Repeat("&lol;", 10) + `</a>`
+	entities := Entities(map[string]string{"lol": lol})
+
+	Ω(limitError(Limits{MaxEntityExpansion: 4000}, src, entities)).Should(BeNil())
+
+	le := limitError(Limits{MaxEntityExpansion: 3000}, src, entities)
+	Ω(le).ShouldNot(BeNil())
+	Ω(le.Limit).Should(Equal("MaxEntityExpansion"))
+	Ω(le.Value).Should(BeNumerically(">", 3000))
+}
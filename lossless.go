@@ -0,0 +1,198 @@
+package xmlproc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// recorder is a reader keeping the bytes consumed by a decoder,
+// so the raw text of every token can be retrieved.
+// It implements io.ByteReader, so xml.Decoder doesn't read ahead.
+type recorder struct {
+	r    *bufio.Reader
+	buf  []byte
+	base int64
+}
+
+func newRecorder(r io.Reader) *recorder {
+	return &recorder{r: bufio.NewReader(r)}
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+func (r *recorder) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, b)
+	}
+	return b, err
+}
+
+// span returns the bytes between the given input offsets.
+func (r *recorder) span(from, to int64) []byte {
+	return r.buf[from-r.base : to-r.base]
+}
+
+// discard forgets the bytes preceding the given input offset.
+func (r *recorder) discard(to int64) {
+	n := copy(r.buf, r.buf[to-r.base:])
+	r.buf = r.buf[:n]
+	r.base = to
+}
+
+// losslessWriter writes the original text of the tokens left untouched by
+// the mappers, and encodes the rest. Namespace URIs of the encoded names are
+// written using the prefixes declared by the original text, see formatter.
+type losslessWriter struct {
+	*formatter
+	rec *recorder
+
+	// pending holds the raw text of a self-closing start tag, until it's
+	// known whether the end tag is modified.
+	pending []byte
+}
+
+func newLosslessWriter(w io.Writer, rec *recorder) *losslessWriter {
+	return &losslessWriter{
+		formatter: &formatter{
+			w:      bufio.NewWriter(w),
+			format: Format{Style: FormatPreserve},
+		},
+		rec: rec,
+	}
+}
+
+// writeTokens writes the tokens produced by the mappers for the input token
+// located between the given input offsets.
+func (w *losslessWriter) writeTokens(in xml.Token, from, to int64, out []xml.Token) error {
+	raw := w.rec.span(from, to)
+	defer w.rec.discard(to)
+
+	if w.pending != nil {
+		pending := w.pending
+		w.pending = nil
+
+		// A self-closing tag produces an end element with no text.
+		if _, ok := in.(xml.EndElement); ok && len(raw) == 0 && len(out) == 1 {
+			if end, ok := out[0].(xml.EndElement); ok {
+				w.writeRaw(end, pending)
+				return nil
+			}
+		}
+		open := append(pending[:len(pending)-2:len(pending)-2], '>')
+		w.write(string(open))
+	}
+
+	if len(out) != 1 || len(raw) == 0 || !sameToken(in, out[0], raw) {
+		for _, t := range out {
+			if err := w.EncodeToken(t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if start, ok := out[0].(xml.StartElement); ok && bytes.HasSuffix(raw, []byte("/>")) {
+		w.open(start)
+		w.depth++
+		w.pending = append([]byte(nil), raw...)
+		return nil
+	}
+	w.writeRaw(out[0], raw)
+	return nil
+}
+
+func (w *losslessWriter) writeRaw(t xml.Token, raw []byte) {
+	switch t := t.(type) {
+	case xml.StartElement:
+		w.open(t)
+		w.depth++
+	case xml.EndElement:
+		if len(w.tags) > 0 {
+			w.close()
+		}
+		w.depth--
+	}
+	w.write(string(raw))
+}
+
+// sameToken reports whether a token was left untouched by the mappers.
+// Element names are also compared in the prefixed form the original text
+// uses, as produced by mappers.NSNormalizer.
+func sameToken(in, out xml.Token, raw []byte) bool {
+	if equalTokens(in, out) {
+		return true
+	}
+
+	switch out.(type) {
+	case xml.StartElement, xml.EndElement:
+		rt, err := xml.NewDecoder(bytes.NewReader(raw)).RawToken()
+		return err == nil && equalTokens(prefixed(rt), out)
+	default:
+		return false
+	}
+}
+
+// prefixed moves the name prefixes of a raw token into the local names.
+func prefixed(t xml.Token) xml.Token {
+	join := func(n xml.Name) xml.Name {
+		if n.Space == "" {
+			return n
+		}
+		return xml.Name{Local: n.Space + ":" + n.Local}
+	}
+
+	switch t := t.(type) {
+	case xml.StartElement:
+		t = t.Copy()
+		t.Name = join(t.Name)
+		for i := range t.Attr {
+			t.Attr[i].Name = join(t.Attr[i].Name)
+		}
+		return t
+	case xml.EndElement:
+		t.Name = join(t.Name)
+		return t
+	default:
+		return t
+	}
+}
+
+func equalTokens(a, b xml.Token) bool {
+	switch a := a.(type) {
+	case xml.StartElement:
+		b, ok := b.(xml.StartElement)
+		if !ok || a.Name != b.Name || len(a.Attr) != len(b.Attr) {
+			return false
+		}
+		for i := range a.Attr {
+			if a.Attr[i] != b.Attr[i] {
+				return false
+			}
+		}
+		return true
+	case xml.EndElement:
+		b, ok := b.(xml.EndElement)
+		return ok && a == b
+	case xml.CharData:
+		b, ok := b.(xml.CharData)
+		return ok && bytes.Equal(a, b)
+	case xml.Comment:
+		b, ok := b.(xml.Comment)
+		return ok && bytes.Equal(a, b)
+	case xml.Directive:
+		b, ok := b.(xml.Directive)
+		return ok && bytes.Equal(a, b)
+	case xml.ProcInst:
+		b, ok := b.(xml.ProcInst)
+		return ok && a.Target == b.Target && bytes.Equal(a.Inst, b.Inst)
+	default:
+		return false
+	}
+}
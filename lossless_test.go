@@ -0,0 +1,97 @@
+package xmlproc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	. "github.com/onsi/gomega"
+)
+
+func lossless(src string, mm ...Mapper) string {
+	dst := &bytes.Buffer{}
+	p := Processor{Mappers: mm, Lossless: true}
+	err := p.ProcessStreams(dst, bytes.NewBufferString(src))
+	Ω(err).ShouldNot(HaveOccurred())
+	return dst.String()
+}
+
+// setAttr sets an attribute of the elements with the given local name.
+type setAttr struct {
+	elem, attr, value string
+}
+
+func (m setAttr) Map(t xml.Token) (xml.Token, error) {
+	if start, ok := t.(xml.StartElement); ok && start.Name.Local == m.elem {
+		for i := range start.Attr {
+			if start.Attr[i].Name.Local == m.attr {
+				start.Attr[i].Value = m.value
+			}
+		}
+	}
+	return t, nil
+}
+
+func TestLosslessUntouched(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<?xml version='1.0'?>
+<!DOCTYPE a>
+<p:a xmlns:p='urn:p'   x = "&#xA;1" >
+  <!-- comment -->
+  <p:b y='&lt;'/>
+  <c>text &amp; &#x41;<![CDATA[<raw>]]></c>
+</p:a>
+`
+	Ω(lossless(src)).Should(Equal(src))
+	Ω(lossless(src, &mappers.NSNormalizer{})).Should(Equal(src))
+}
+
+func TestLosslessModified(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<p:a xmlns:p='urn:p' x='1'>
+  <p:b  y='1'/>
+  <p:b  y='2' />
+  <c y='3'/>
+</p:a>`
+
+	Ω(lossless(src, &mappers.NSNormalizer{}, setAttr{"p:b", "y", "0"})).Should(Equal(`<p:a xmlns:p='urn:p' x='1'>
+  <p:b y="0"></p:b>
+  <p:b y="0"></p:b>
+  <c y='3'/>
+</p:a>`))
+
+	Ω(lossless(src, &mappers.NSNormalizer{}, setAttr{"p:a", "x", "2"}, &mappers.Pruner{})).Should(Equal(
+		`<p:a xmlns:p="urn:p" x="2"><p:b  y='1'/><p:b  y='2' /><c y='3'/></p:a>`))
+}
+
+func TestLosslessModifiedNotNormalized(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<r xmlns:p="urn:p"><p:a p:q="2"/><p:b/></r>`
+	m := mappers.SetAttr(xml.Name{Space: "urn:p", Local: "a"}, xml.Name{Local: "z"}, "1")
+	Ω(lossless(src, m)).Should(Equal(`<r xmlns:p="urn:p"><p:a p:q="2" z="1"></p:a><p:b/></r>`))
+
+	m = mappers.SetAttr(xml.Name{Space: "urn:p", Local: "b"}, xml.Name{Space: "urn:q", Local: "z"}, "1")
+	Ω(lossless(src, m)).Should(Equal(`<r xmlns:p="urn:p"><p:a p:q="2"/><p:b ns1:z="1" xmlns:ns1="urn:q"></p:b></r>`))
+}
+
+func TestLosslessSelfClosingContent(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<a><b x='1'/></a>`
+	Ω(lossless(src, annotator{})).Should(Equal(`<!-- a --><a><!-- b --><b x="1"></b></a>`))
+
+	child := &Subtree{
+		Match: func(c *Context, t xml.StartElement) bool {
+			return t.Name.Local == "b"
+		},
+		Func: func(c *Context, n *Node) (*Node, error) {
+			n.Children = append(n.Children, NewText("text"))
+			return n, nil
+		},
+	}
+	Ω(lossless(src, child)).Should(Equal(`<a><b x="1">text</b></a>`))
+}
@@ -1,6 +1,10 @@
 package xmlproc
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+)
 
 // Mapper is an interface encapsulating the actual transformation
 // (or other work) to be performed to a XML document.
@@ -12,3 +16,55 @@ import "encoding/xml"
 type Mapper interface {
 	Map(xml.Token) (xml.Token, error)
 }
+
+// MultiMapper is an optional interface a Mapper may implement in order to
+// replace a single token with any number of tokens: none, one or many.
+// When a mapper implements MultiMapper, the Processor calls MapTokens
+// instead of Map. The returned tokens are passed, in order, to the rest of
+// the mappers in the chain.
+type MultiMapper interface {
+	MapTokens(xml.Token) ([]xml.Token, error)
+}
+
+// Context describes the position of a processed token in a document,
+// see mappers.Context.
+type Context = mappers.Context
+
+// ContextMapper is an optional interface a Mapper may implement in order to
+// get the context of a processed token, e.g. the enclosing elements.
+// When a mapper implements ContextMapper, the Processor calls MapContext
+// instead of Map or MapTokens. Similarly to MultiMapper, the mapper may
+// return any number of tokens.
+// The context is owned by the processor and must not be retained or
+// modified by mappers.
+type ContextMapper interface {
+	MapContext(*Context, xml.Token) ([]xml.Token, error)
+}
+
+// ErrMultipleTokens is returned by the Map method of a multi-token mapper
+// when it is called directly and the mapping produces more than one token.
+var ErrMultipleTokens = mappers.ErrMultipleTokens
+
+// Apply calls the given mapper for a token using the richest interface
+// the mapper implements, and returns the resulting tokens.
+// A nil token returned by Map is translated to an empty result.
+func Apply(m Mapper, c *Context, t xml.Token) ([]xml.Token, error) {
+	if cm, ok := m.(ContextMapper); ok {
+		return cm.MapContext(c, t)
+	}
+	if mm, ok := m.(MultiMapper); ok {
+		return mm.MapTokens(t)
+	}
+
+	token, err := m.Map(t)
+	if err != nil || token == nil {
+		return nil, err
+	}
+	return []xml.Token{token}, nil
+}
+
+// Single converts a result of a multi-token mapping into a single token.
+// It's intended for implementing Map method of multi-token mappers.
+func Single(ts []xml.Token, err error) (xml.Token, error) {
+	return mappers.Single(ts, err)
+}
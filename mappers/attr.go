@@ -0,0 +1,151 @@
+package mappers
+
+import "encoding/xml"
+
+// AttrFunc computes a new value of an attribute from the current one.
+// The present argument reports whether the attribute is set; the keep
+// result reports whether the attribute should be set after the change.
+type AttrFunc func(value string, present bool) (newValue string, keep bool, err error)
+
+// Attr is a mapper editing an attribute of elements.
+// Element and attribute names are matched by namespace URI and local name,
+// both before and after NSNormalizer processes the tokens. An empty Space
+// matches any namespace; an empty Element.Local matches any element.
+// Match, if set, filters the elements further; it gets the start tags with
+// the names resolved to the namespace URIs.
+// Namespace declarations are never matched.
+// Use SetAttr, AddAttr, RemoveAttr, RenameAttr and TransformAttr to create
+// the mapper.
+type Attr struct {
+	Element xml.Name
+	Match   func(xml.StartElement) bool
+	Name    xml.Name
+	// ExactName makes an empty Space of Name match only the attributes
+	// having no namespace.
+	ExactName bool
+	// Func, if set, computes the new value of the attribute.
+	Func AttrFunc
+	// NewName, if set, renames the attribute.
+	NewName xml.Name
+	// Prefixes maps namespace URIs to the preferred prefixes, used to
+	// declare the namespaces of the added and renamed attributes missing
+	// in the scope of an element; other prefixes are generated.
+	Prefixes map[string]string
+
+	ns nsTracker
+}
+
+// SetAttr sets an attribute, overwriting the current value, if any.
+func SetAttr(element, name xml.Name, value string) *Attr {
+	return &Attr{Element: element, Name: name, Func: func(string, bool) (string, bool, error) {
+		return value, true, nil
+	}}
+}
+
+// AddAttr adds an attribute if it's missing.
+func AddAttr(element, name xml.Name, value string) *Attr {
+	return &Attr{Element: element, Name: name, Func: func(v string, ok bool) (string, bool, error) {
+		if ok {
+			return v, true, nil
+		}
+		return value, true, nil
+	}}
+}
+
+// RemoveAttr removes an attribute.
+func RemoveAttr(element, name xml.Name) *Attr {
+	return &Attr{Element: element, Name: name, Func: func(string, bool) (string, bool, error) {
+		return "", false, nil
+	}}
+}
+
+// RenameAttr renames an attribute, keeping its value.
+func RenameAttr(element, name, newName xml.Name) *Attr {
+	return &Attr{Element: element, Name: name, NewName: newName}
+}
+
+// TransformAttr replaces the value of an existing attribute with the result
+// of a function.
+func TransformAttr(element, name xml.Name, fn func(string) (string, error)) *Attr {
+	return &Attr{Element: element, Name: name, Func: func(v string, ok bool) (string, bool, error) {
+		if !ok {
+			return "", false, nil
+		}
+		v, err := fn(v)
+		return v, true, err
+	}}
+}
+
+func (m *Attr) Map(t xml.Token) (xml.Token, error) {
+	switch token := t.(type) {
+	case xml.StartElement:
+		m.ns.push(token)
+		if !m.ns.matchElement(m.Element, m.Match, token) {
+			return t, nil
+		}
+		return m.edit(token.Copy())
+
+	case xml.EndElement:
+		m.ns.pop()
+		return t, nil
+
+	default:
+		return t, nil
+	}
+}
+
+func (m *Attr) edit(t xml.StartElement) (xml.Token, error) {
+	idx := -1
+	for i, a := range t.Attr {
+		if _, ok := isNSDecl(a); ok {
+			continue
+		}
+		name := m.ns.resolve(a.Name, true)
+		if matchName(m.Name, name) && (!m.ExactName || name.Space == m.Name.Space) {
+			idx = i
+			break
+		}
+	}
+
+	if m.Func != nil {
+		var value string
+		if idx >= 0 {
+			value = t.Attr[idx].Value
+		}
+
+		value, keep, err := m.Func(value, idx >= 0)
+		switch {
+		case err != nil:
+			return nil, err
+		case !keep && idx >= 0:
+			t.Attr = append(t.Attr[:idx], t.Attr[idx+1:]...)
+			idx = -1
+		case keep && idx >= 0:
+			t.Attr[idx].Value = value
+		case keep:
+			t.Attr = append(t.Attr, xml.Attr{Value: value})
+			idx = len(t.Attr) - 1
+			// qualify may append a declaration, reallocating t.Attr.
+			name := m.qualify(&t, m.Name)
+			t.Attr[idx].Name = name
+		}
+	}
+
+	if m.NewName.Local != "" && idx >= 0 {
+		name := m.qualify(&t, m.NewName)
+		t.Attr[idx].Name = name
+	}
+	return t, nil
+}
+
+// qualify converts an attribute name into the form of a start tag,
+// declaring the namespace of the name if it's not declared in scope.
+func (m *Attr) qualify(t *xml.StartElement, name xml.Name) xml.Name {
+	normalized := isNormalized(*t)
+	if name.Space != "" {
+		if _, ok := m.ns.uriPrefix(name.Space, true); !ok {
+			t.Attr = append(t.Attr, m.ns.declare(name.Space, m.Prefixes, normalized))
+		}
+	}
+	return m.ns.qualify(name, true, normalized)
+}
@@ -0,0 +1,111 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const attrDoc = `<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="1" p:type="t" q:type="u"></p:a><b id="2"></b></r>`
+
+var (
+	elemA   = xml.Name{Space: "urn:p", Local: "a"}
+	anyName = xml.Name{}
+	id      = xml.Name{Local: "id"}
+	pType   = xml.Name{Space: "urn:p", Local: "type"}
+)
+
+func TestAttrBeforeAndAfterNormalizer(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, tc := range []struct {
+		mapper *Attr
+		res    string
+	}{
+		{
+			SetAttr(elemA, id, "x"),
+			`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="x" p:type="t" q:type="u"></p:a><b id="2"></b></r>`,
+		},
+		{
+			SetAttr(anyName, pType, "x"),
+			`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q" p:type="x"><p:a id="1" p:type="x" q:type="u"></p:a><b id="2" p:type="x"></b></r>`,
+		},
+		{
+			AddAttr(anyName, id, "0"),
+			`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q" id="0"><p:a id="1" p:type="t" q:type="u"></p:a><b id="2"></b></r>`,
+		},
+		{
+			RemoveAttr(elemA, pType),
+			`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="1" q:type="u"></p:a><b id="2"></b></r>`,
+		},
+		{
+			RemoveAttr(elemA, xml.Name{Local: "type"}),
+			`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="1" q:type="u"></p:a><b id="2"></b></r>`,
+		},
+		{
+			RenameAttr(xml.Name{Space: "urn:r", Local: "b"}, id, xml.Name{Space: "urn:q", Local: "ref"}),
+			`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="1" p:type="t" q:type="u"></p:a><b q:ref="2"></b></r>`,
+		},
+		{
+			TransformAttr(anyName, id, func(v string) (string, error) { return v + v, nil }),
+			`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="11" p:type="t" q:type="u"></p:a><b id="22"></b></r>`,
+		},
+	} {
+		before := *tc.mapper
+		after := *tc.mapper
+		Ω(process(attrDoc, &before, &NSNormalizer{})).Should(Equal(tc.res))
+		Ω(process(attrDoc, &NSNormalizer{}, &after)).Should(Equal(tc.res))
+	}
+}
+
+func TestAttrNamespaceDecls(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := RemoveAttr(anyName, xml.Name{Local: "p"})
+	Ω(process(attrDoc, m, &NSNormalizer{})).Should(Equal(attrDoc))
+
+	m = RemoveAttr(anyName, xml.Name{Local: "xmlns"})
+	Ω(process(attrDoc, &NSNormalizer{}, m)).Should(Equal(attrDoc))
+}
+
+func TestAttrError(t *testing.T) {
+	RegisterTestingT(t)
+
+	failure := errors.New("failure")
+	m := TransformAttr(anyName, id, func(v string) (string, error) {
+		if strings.HasPrefix(v, "2") {
+			return "", failure
+		}
+		return v, nil
+	})
+
+	_, err := m.Map(xml.StartElement{Name: xml.Name{Local: "a"}, Attr: []xml.Attr{{Name: id, Value: "1"}}})
+	Ω(err).ShouldNot(HaveOccurred())
+	_, err = m.Map(xml.StartElement{Name: xml.Name{Local: "a"}, Attr: []xml.Attr{{Name: id, Value: "2"}}})
+	Ω(err).Should(Equal(failure))
+}
+
+func TestAttrUndeclaredNamespace(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := SetAttr(xml.Name{Local: "b"}, xml.Name{Space: "urn:n", Local: "id"}, "x")
+	Ω(process(attrDoc, &NSNormalizer{}, m)).Should(Equal(
+		`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="1" p:type="t" q:type="u"></p:a><b id="2" ns1:id="x" xmlns:ns1="urn:n"></b></r>`))
+
+	m = RenameAttr(elemA, id, xml.Name{Space: "urn:n", Local: "ref"})
+	m.Prefixes = map[string]string{"urn:n": "n"}
+	Ω(process(attrDoc, &NSNormalizer{}, m)).Should(Equal(
+		`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a n:ref="1" p:type="t" q:type="u" xmlns:n="urn:n"></p:a><b id="2"></b></r>`))
+}
+
+func TestAttrExactName(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := SetAttr(elemA, xml.Name{Local: "type"}, "x")
+	m.ExactName = true
+	Ω(process(attrDoc, m, &NSNormalizer{})).Should(Equal(
+		`<r xmlns="urn:r" xmlns:p="urn:p" xmlns:q="urn:q"><p:a id="1" p:type="t" q:type="u" type="x"></p:a><b id="2"></b></r>`))
+}
@@ -0,0 +1,197 @@
+package mappers
+
+import (
+	"context"
+	"encoding/xml"
+	"strings"
+)
+
+// Element describes an element open at the current position of a document.
+type Element struct {
+	// Name and Attr are the resolved name and attributes of the element,
+	// as produced by the decoder.
+	Name xml.Name
+	Attr []xml.Attr
+	// Index is the position of the element among its sibling elements,
+	// starting from 0.
+	Index int
+	// NameIndex is the position of the element among its sibling elements
+	// with the same name, starting from 0.
+	NameIndex int
+
+	children int
+	names    map[xml.Name]int
+}
+
+// AttrValue returns the value of the element attribute with the given name.
+// An empty name space matches any attribute name space.
+func (e Element) AttrValue(name xml.Name) (string, bool) {
+	for _, a := range e.Attr {
+		if a.Name.Local == name.Local && (name.Space == "" || a.Name.Space == name.Space) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Path is the stack of open elements, the root element first.
+type Path []Element
+
+// Depth returns the number of open elements.
+func (p Path) Depth() int {
+	return len(p)
+}
+
+// Top returns the innermost open element, or nil if there's none.
+func (p Path) Top() *Element {
+	if len(p) == 0 {
+		return nil
+	}
+	return &p[len(p)-1]
+}
+
+// Parent returns the parent of the innermost open element, or nil.
+func (p Path) Parent() *Element {
+	if len(p) < 2 {
+		return nil
+	}
+	return &p[len(p)-2]
+}
+
+// Position returns the position of the innermost element among its sibling
+// elements having the names accepted by the function, starting from 1.
+// Only the preceding siblings are known, and the element itself is counted
+// if accepted.
+func (p Path) Position(accept func(xml.Name) bool) int {
+	parent := p.Parent()
+	if parent == nil {
+		return 1
+	}
+	pos := 0
+	for name, n := range parent.names {
+		if accept(name) {
+			pos += n
+		}
+	}
+	return pos
+}
+
+// LookupPrefix returns the namespace URI bound to the given prefix by
+// the namespace declarations of the open elements.
+// An empty prefix stands for the default namespace.
+func (p Path) LookupPrefix(prefix string) (string, bool) {
+	for i := len(p) - 1; i >= 0; i-- {
+		for _, a := range p[i].Attr {
+			if prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns" {
+				return a.Value, true
+			} else if prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix {
+				return a.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// LookupURI returns a prefix bound to the given namespace URI by
+// the namespace declarations of the open elements.
+func (p Path) LookupURI(uri string) (string, bool) {
+	for i := len(p) - 1; i >= 0; i-- {
+		for _, a := range p[i].Attr {
+			if a.Name.Space != "xmlns" || a.Value != uri {
+				continue
+			}
+			// Make sure the prefix is not redeclared by inner elements.
+			if u, _ := p.LookupPrefix(a.Name.Local); u == uri {
+				return a.Name.Local, true
+			}
+		}
+	}
+	return "", false
+}
+
+// String returns a slash separated list of the open element names.
+// Names are written with a prefix declared in the document when possible,
+// and in {uri}local form otherwise.
+func (p Path) String() string {
+	var b strings.Builder
+	for _, e := range p {
+		b.WriteString("/")
+		b.WriteString(p.format(e.Name))
+	}
+	return b.String()
+}
+
+func (p Path) format(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	if uri, _ := p.LookupPrefix(""); uri == name.Space {
+		return name.Local
+	}
+	if prefix, ok := p.LookupURI(name.Space); ok {
+		return prefix + ":" + name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+// Context describes the position of a processed token in a document.
+// It's maintained by the processor and passed to context-aware mappers.
+// The context always reflects the input document, regardless of changes
+// made by mappers.
+type Context struct {
+	// Path holds the elements enclosing the current token.
+	// For xml.StartElement and xml.EndElement tokens the path includes
+	// the element itself.
+	Path Path
+	// Offset, Line and Column point at the beginning of the current token
+	// in the input document.
+	Offset int64
+	Line   int
+	Column int
+
+	ctx  context.Context
+	root Element
+}
+
+// NewContext creates a token context bound to the given context.Context.
+func NewContext(ctx context.Context) *Context {
+	return &Context{ctx: ctx}
+}
+
+// Context returns the context.Context the processing is bound to.
+// Long running mappers should observe its cancellation.
+func (c *Context) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Enter pushes a new element onto the path.
+func (c *Context) Enter(t xml.StartElement) {
+	parent := &c.root
+	if top := c.Path.Top(); top != nil {
+		parent = top
+	}
+	if parent.names == nil {
+		parent.names = map[xml.Name]int{}
+	}
+
+	e := Element{
+		Name:      t.Name,
+		Attr:      append([]xml.Attr(nil), t.Attr...),
+		Index:     parent.children,
+		NameIndex: parent.names[t.Name],
+	}
+	parent.children++
+	parent.names[t.Name]++
+
+	c.Path = append(c.Path, e)
+}
+
+// Leave pops the innermost element from the path.
+func (c *Context) Leave() {
+	if len(c.Path) > 0 {
+		c.Path = c.Path[:len(c.Path)-1]
+	}
+}
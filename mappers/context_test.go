@@ -0,0 +1,90 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestContextEnterLeave(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := Context{}
+	Ω(c.Path.Depth()).Should(Equal(0))
+	Ω(c.Path.Top()).Should(BeNil())
+	Ω(c.Path.Parent()).Should(BeNil())
+
+	a := xml.Name{Space: "urn:a", Local: "a"}
+	b := xml.Name{Space: "urn:a", Local: "b"}
+	d := xml.Name{Local: "d"}
+
+	c.Enter(xml.StartElement{Name: a, Attr: []xml.Attr{
+		{Name: xml.Name{Space: "xmlns", Local: "x"}, Value: "urn:a"},
+	}})
+	Ω(c.Path.Depth()).Should(Equal(1))
+	Ω(c.Path.Top().Name).Should(Equal(a))
+	Ω(c.Path.Top().Index).Should(Equal(0))
+	Ω(c.Path.String()).Should(Equal("/x:a"))
+
+	c.Enter(xml.StartElement{Name: b})
+	Ω(c.Path.Top().Index).Should(Equal(0))
+	Ω(c.Path.Top().NameIndex).Should(Equal(0))
+	Ω(c.Path.Parent().Name).Should(Equal(a))
+	c.Leave()
+
+	c.Enter(xml.StartElement{Name: d})
+	Ω(c.Path.Top().Index).Should(Equal(1))
+	Ω(c.Path.Top().NameIndex).Should(Equal(0))
+	c.Leave()
+
+	c.Enter(xml.StartElement{Name: b})
+	Ω(c.Path.Top().Index).Should(Equal(2))
+	Ω(c.Path.Top().NameIndex).Should(Equal(1))
+	Ω(c.Path.String()).Should(Equal("/x:a/x:b"))
+	c.Leave()
+
+	c.Leave()
+	Ω(c.Path.Depth()).Should(Equal(0))
+	c.Leave()
+	Ω(c.Path.Depth()).Should(Equal(0))
+}
+
+func TestPathLookup(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := Context{}
+	c.Enter(xml.StartElement{
+		Name: xml.Name{Space: "urn:a", Local: "a"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns"}, Value: "urn:a"},
+			{Name: xml.Name{Space: "xmlns", Local: "p"}, Value: "urn:p"},
+		},
+	})
+	c.Enter(xml.StartElement{
+		Name: xml.Name{Space: "urn:q", Local: "b"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Space: "xmlns", Local: "p"}, Value: "urn:q"},
+		},
+	})
+
+	uri, ok := c.Path.LookupPrefix("")
+	Ω(ok).Should(BeTrue())
+	Ω(uri).Should(Equal("urn:a"))
+
+	uri, ok = c.Path.LookupPrefix("p")
+	Ω(ok).Should(BeTrue())
+	Ω(uri).Should(Equal("urn:q"))
+
+	_, ok = c.Path.LookupPrefix("z")
+	Ω(ok).Should(BeFalse())
+
+	_, ok = c.Path.LookupURI("urn:p")
+	Ω(ok).Should(BeFalse())
+
+	prefix, ok := c.Path.LookupURI("urn:q")
+	Ω(ok).Should(BeTrue())
+	Ω(prefix).Should(Equal("p"))
+
+	Ω(c.Path.String()).Should(Equal("/a/p:b"))
+}
@@ -0,0 +1,75 @@
+package mappers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+
+	. "github.com/onsi/gomega"
+)
+
+type mapper interface {
+	Map(xml.Token) (xml.Token, error)
+}
+
+// process runs a document through the mappers, maintaining the context
+// similarly to xmlproc.Processor, and returns the encoded result.
+func process(src string, mm ...mapper) string {
+	dst := &bytes.Buffer{}
+	enc := xml.NewEncoder(dst)
+	dec := xml.NewDecoder(bytes.NewBufferString(src))
+	c := &Context{}
+
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		Ω(err).ShouldNot(HaveOccurred())
+
+		t = xml.CopyToken(t)
+		if start, ok := t.(xml.StartElement); ok {
+			c.Enter(start)
+		}
+
+		tokens := []xml.Token{t}
+		for _, m := range mm {
+			var next []xml.Token
+			for _, t := range tokens {
+				if cm, ok := m.(interface {
+					MapContext(*Context, xml.Token) ([]xml.Token, error)
+				}); ok {
+					res, err := cm.MapContext(c, t)
+					Ω(err).ShouldNot(HaveOccurred())
+					next = append(next, res...)
+					continue
+				}
+				if multi, ok := m.(interface {
+					MapTokens(xml.Token) ([]xml.Token, error)
+				}); ok {
+					res, err := multi.MapTokens(t)
+					Ω(err).ShouldNot(HaveOccurred())
+					next = append(next, res...)
+					continue
+				}
+
+				res, err := m.Map(t)
+				Ω(err).ShouldNot(HaveOccurred())
+				if res != nil {
+					next = append(next, res)
+				}
+			}
+			tokens = next
+		}
+
+		for _, t := range tokens {
+			Ω(enc.EncodeToken(t)).ShouldNot(HaveOccurred())
+		}
+		if _, ok := t.(xml.EndElement); ok {
+			c.Leave()
+		}
+	}
+
+	Ω(enc.Flush()).ShouldNot(HaveOccurred())
+	return dst.String()
+}
@@ -0,0 +1,102 @@
+package mappers
+
+import "encoding/xml"
+
+// Insert is a mapper inserting tokens around and into elements.
+// Elements are matched by Element, similarly to Attr, and by Func, if set.
+// Func gets the start tag with the name resolved to the namespace URI.
+//
+// The inserted tokens are usually created by ParseTokens or MarshalTokens,
+// and hold namespace URIs in their names, so the mapper is expected to run
+// before NSNormalizer.
+// Use InsertBefore, InsertAfter, PrependChild, AppendChild and Wrap to
+// create the mapper.
+type Insert struct {
+	Element xml.Name
+	Func    func(xml.StartElement) bool
+
+	// Before and After are inserted in front of the start tag and after
+	// the end tag; Prepend and Append are inserted after the start tag
+	// and in front of the end tag.
+	Before  []xml.Token
+	Prepend []xml.Token
+	Append  []xml.Token
+	After   []xml.Token
+
+	ns    nsTracker
+	stack []bool
+}
+
+// InsertBefore inserts tokens in front of the elements.
+func InsertBefore(element xml.Name, ts ...xml.Token) *Insert {
+	return &Insert{Element: element, Before: ts}
+}
+
+// InsertAfter inserts tokens after the elements.
+func InsertAfter(element xml.Name, ts ...xml.Token) *Insert {
+	return &Insert{Element: element, After: ts}
+}
+
+// PrependChild inserts tokens at the beginning of the content of the
+// elements.
+func PrependChild(element xml.Name, ts ...xml.Token) *Insert {
+	return &Insert{Element: element, Prepend: ts}
+}
+
+// AppendChild inserts tokens at the end of the content of the elements.
+func AppendChild(element xml.Name, ts ...xml.Token) *Insert {
+	return &Insert{Element: element, Append: ts}
+}
+
+// Wrap puts every element into a new parent element.
+func Wrap(element xml.Name, parent xml.StartElement) *Insert {
+	return &Insert{
+		Element: element,
+		Before:  []xml.Token{parent},
+		After:   []xml.Token{parent.End()},
+	}
+}
+
+func (m *Insert) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapTokens(t))
+}
+
+func (m *Insert) MapTokens(t xml.Token) ([]xml.Token, error) {
+	switch token := t.(type) {
+	case xml.StartElement:
+		m.ns.push(token)
+		matched := m.ns.matchElement(m.Element, m.Func, token)
+		m.stack = append(m.stack, matched)
+		if !matched {
+			return []xml.Token{t}, nil
+		}
+		return join(m.Before, []xml.Token{t}, m.Prepend), nil
+
+	case xml.EndElement:
+		m.ns.pop()
+		if len(m.stack) == 0 {
+			return []xml.Token{t}, nil
+		}
+		matched := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		if !matched {
+			return []xml.Token{t}, nil
+		}
+		return join(m.Append, []xml.Token{t}, m.After), nil
+
+	default:
+		return []xml.Token{t}, nil
+	}
+}
+
+// join concatenates token lists, copying the tokens, so the inserted ones
+// can be modified by the following mappers.
+func join(lists ...[]xml.Token) []xml.Token {
+	var res []xml.Token
+	for _, ts := range lists {
+		for _, t := range ts {
+			res = append(res, xml.CopyToken(t))
+		}
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestInsert(t *testing.T) {
+	RegisterTestingT(t)
+
+	const doc = `<r xmlns:p="urn:p"><p:a>x</p:a><b></b></r>`
+	note := MustParseTokens(`<note>n</note>`)
+
+	for _, tc := range []struct {
+		mapper *Insert
+		res    string
+	}{
+		{
+			InsertBefore(elemA, note...),
+			`<r xmlns:p="urn:p"><note>n</note><p:a>x</p:a><b></b></r>`,
+		},
+		{
+			InsertAfter(elemA, note...),
+			`<r xmlns:p="urn:p"><p:a>x</p:a><note>n</note><b></b></r>`,
+		},
+		{
+			PrependChild(elemA, note...),
+			`<r xmlns:p="urn:p"><p:a><note>n</note>x</p:a><b></b></r>`,
+		},
+		{
+			AppendChild(xml.Name{Local: "b"}, note...),
+			`<r xmlns:p="urn:p"><p:a>x</p:a><b><note>n</note></b></r>`,
+		},
+		{
+			Wrap(elemA, xml.StartElement{Name: xml.Name{Space: "urn:p", Local: "list"}}),
+			`<r xmlns:p="urn:p"><p:list><p:a>x</p:a></p:list><b></b></r>`,
+		},
+	} {
+		Ω(process(doc, tc.mapper, &NSNormalizer{})).Should(Equal(tc.res))
+	}
+}
+
+func TestInsertNested(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := InsertAfter(xml.Name{Local: "a"}, xml.CharData("!"))
+	Ω(process(`<a><b><a></a></b></a>`, m)).Should(Equal(`<a><b><a></a>!</b></a>!`))
+
+	_, err := m.Map(xml.EndElement{})
+	Ω(err).ShouldNot(HaveOccurred())
+}
+
+func TestMarshalTokens(t *testing.T) {
+	RegisterTestingT(t)
+
+	type Item struct {
+		XMLName xml.Name `xml:"urn:i item"`
+		ID      string   `xml:"id,attr"`
+		Title   string   `xml:"title"`
+	}
+
+	ts, err := MarshalTokens(Item{ID: "1", Title: "t"})
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(ts).Should(Equal([]xml.Token{
+		xml.StartElement{
+			Name: xml.Name{Space: "urn:i", Local: "item"},
+			Attr: []xml.Attr{
+				{Name: xml.Name{Local: "xmlns"}, Value: "urn:i"},
+				{Name: xml.Name{Local: "id"}, Value: "1"},
+			},
+		},
+		xml.StartElement{Name: xml.Name{Space: "urn:i", Local: "title"}, Attr: []xml.Attr{}},
+		xml.CharData("t"),
+		xml.EndElement{Name: xml.Name{Space: "urn:i", Local: "title"}},
+		xml.EndElement{Name: xml.Name{Space: "urn:i", Local: "item"}},
+	}))
+
+	_, err = ParseTokens(`<a>`)
+	Ω(err).Should(HaveOccurred())
+}
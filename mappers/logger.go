@@ -1,14 +1,199 @@
 package mappers
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
 )
 
-// Logger prints to stdout a token being processed.
-type Logger struct{}
+// TokenKind is a set of kinds of tokens.
+type TokenKind int
 
-func (p Logger) Map(t xml.Token) (xml.Token, error) {
-	fmt.Printf("  processing token: %#v\n", t)
-	return t, nil
+const (
+	KindStartElement TokenKind = 1 << iota
+	KindEndElement
+	KindCharData
+	KindComment
+	KindProcInst
+	KindDirective
+
+	// KindAll is the set of all kinds of tokens.
+	KindAll = KindStartElement | KindEndElement | KindCharData |
+		KindComment | KindProcInst | KindDirective
+)
+
+// TokenKindOf returns the kind of a token.
+func TokenKindOf(t xml.Token) TokenKind {
+	switch t.(type) {
+	case xml.StartElement:
+		return KindStartElement
+	case xml.EndElement:
+		return KindEndElement
+	case xml.CharData:
+		return KindCharData
+	case xml.Comment:
+		return KindComment
+	case xml.ProcInst:
+		return KindProcInst
+	case xml.Directive:
+		return KindDirective
+	default:
+		return 0
+	}
+}
+
+func (k TokenKind) String() string {
+	var names []string
+	for _, kind := range []struct {
+		kind TokenKind
+		name string
+	}{
+		{KindStartElement, "start"},
+		{KindEndElement, "end"},
+		{KindCharData, "chardata"},
+		{KindComment, "comment"},
+		{KindProcInst, "procinst"},
+		{KindDirective, "directive"},
+	} {
+		if k&kind.kind != 0 {
+			names = append(names, kind.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// LogFormat defines the format of the entries written by Logger.
+type LogFormat int
+
+const (
+	// LogText writes a line per token: the offset, the path of the
+	// enclosing elements and the token in XML-like notation.
+	LogText LogFormat = iota
+	// LogJSON writes a JSON object per line.
+	LogJSON
+)
+
+// Logger logs the tokens being processed, passing them through unchanged.
+// The entries are written to Writer in the given Format, or to Slog, if
+// set. The zero value writes text entries to stdout.
+// The path and the offset of tokens are only known when the mapper runs
+// as a context-aware mapper, e.g. in xmlproc.Processor.
+type Logger struct {
+	Writer io.Writer
+	Format LogFormat
+	// Slog, if set, gets the entries as records of the given Level.
+	Slog  *slog.Logger
+	Level slog.Level
+	// Kinds of the logged tokens, all of them if zero.
+	Kinds TokenKind
+	// Sample, if greater than 1, makes only every Sample-th token of
+	// the logged kinds logged, e.g. for huge documents.
+	Sample int
+
+	count int
+}
+
+// logEntry is a JSON representation of a logged token.
+type logEntry struct {
+	Offset int64  `json:"offset"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+	Token  string `json:"token"`
+}
+
+func (p *Logger) Map(t xml.Token) (xml.Token, error) {
+	return Single(p.MapContext(&Context{}, t))
+}
+
+func (p *Logger) MapContext(c *Context, t xml.Token) ([]xml.Token, error) {
+	kind := TokenKindOf(t)
+	if p.Kinds != 0 && p.Kinds&kind == 0 {
+		return []xml.Token{t}, nil
+	}
+	p.count++
+	if p.Sample > 1 && (p.count-1)%p.Sample != 0 {
+		return []xml.Token{t}, nil
+	}
+
+	e := logEntry{
+		Offset: c.Offset,
+		Line:   c.Line,
+		Column: c.Column,
+		Path:   c.Path.String(),
+		Kind:   kind.String(),
+		Token:  formatToken(c.Path, t),
+	}
+	if e.Path == "" {
+		e.Path = "/"
+	}
+
+	if p.Slog != nil {
+		p.Slog.LogAttrs(c.Context(), p.Level, "token",
+			slog.Int64("offset", e.Offset),
+			slog.Int("line", e.Line),
+			slog.Int("column", e.Column),
+			slog.String("path", e.Path),
+			slog.String("kind", e.Kind),
+			slog.String("token", e.Token),
+		)
+		return []xml.Token{t}, nil
+	}
+
+	w := p.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	if p.Format == LogJSON {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	} else if _, err := fmt.Fprintf(w, "%d %s %s\n", e.Offset, e.Path, e.Token); err != nil {
+		return nil, err
+	}
+	return []xml.Token{t}, nil
+}
+
+// formatToken returns an XML-like notation of a token. Namespace URIs of
+// names are replaced with the prefixes declared by the path elements.
+func formatToken(path Path, t xml.Token) string {
+	switch t := t.(type) {
+	case xml.StartElement:
+		var b strings.Builder
+		b.WriteString("<" + path.format(t.Name))
+		for _, a := range t.Attr {
+			name := a.Name.Local
+			if a.Name.Space == "xmlns" {
+				name = "xmlns:" + name
+			} else if a.Name.Space != "" {
+				name = path.format(a.Name)
+			}
+			b.WriteString(" " + name + "=" + strconv.Quote(a.Value))
+		}
+		b.WriteString(">")
+		return b.String()
+	case xml.EndElement:
+		return "</" + path.format(t.Name) + ">"
+	case xml.CharData:
+		return strconv.Quote(string(t))
+	case xml.Comment:
+		return "<!--" + string(t) + "-->"
+	case xml.ProcInst:
+		if len(t.Inst) == 0 {
+			return "<?" + t.Target + "?>"
+		}
+		return "<?" + t.Target + " " + string(t.Inst) + "?>"
+	case xml.Directive:
+		return "<!" + string(t) + ">"
+	default:
+		return fmt.Sprintf("%#v", t)
+	}
 }
@@ -0,0 +1,105 @@
+package mappers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"log/slog"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// logTokens runs the tokens of a document through a logger, maintaining
+// the context similarly to xmlproc.Processor.
+func logTokens(l *Logger, src string) {
+	c := &Context{}
+	d := xml.NewDecoder(bytes.NewBufferString(src))
+	for {
+		c.Offset = d.InputOffset()
+		t, err := d.Token()
+		if err != nil {
+			return
+		}
+		if start, ok := t.(xml.StartElement); ok {
+			c.Enter(start)
+		}
+		res, err := l.MapContext(c, t)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(res).Should(Equal([]xml.Token{t}))
+		if _, ok := t.(xml.EndElement); ok {
+			c.Leave()
+		}
+	}
+}
+
+const logDoc = `<r xmlns:p="urn:p"><p:a id="1">x</p:a><!--c--><b/></r>`
+
+func TestLoggerText(t *testing.T) {
+	RegisterTestingT(t)
+
+	out := &bytes.Buffer{}
+	logTokens(&Logger{Writer: out}, logDoc)
+	Ω(out.String()).Should(Equal(`0 /r <r xmlns:p="urn:p">
+19 /r/p:a <p:a id="1">
+31 /r/p:a "x"
+32 /r/p:a </p:a>
+38 /r <!--c-->
+46 /r/b <b>
+50 /r/b </b>
+50 /r </r>
+`))
+}
+
+func TestLoggerFilters(t *testing.T) {
+	RegisterTestingT(t)
+
+	out := &bytes.Buffer{}
+	logTokens(&Logger{Writer: out, Format: LogJSON, Kinds: KindStartElement, Sample: 2}, logDoc)
+	Ω(out.String()).Should(Equal(
+		`{"offset":0,"line":0,"column":0,"path":"/r","kind":"start","token":"<r xmlns:p=\"urn:p\">"}
+{"offset":46,"line":0,"column":0,"path":"/r/b","kind":"start","token":"<b>"}
+`))
+}
+
+func TestLoggerSlog(t *testing.T) {
+	RegisterTestingT(t)
+
+	out := &bytes.Buffer{}
+	h := slog.NewTextHandler(out, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logTokens(&Logger{Slog: slog.New(h), Kinds: KindCharData}, logDoc)
+	Ω(out.String()).Should(Equal(
+		`level=INFO msg=token offset=31 line=0 column=0 path=/r/p:a kind=chardata token="\"x\""` + "\n"))
+}
+
+// ctxHandler records the contexts of the logged records.
+type ctxHandler struct {
+	slog.Handler
+	ctxs *[]context.Context
+}
+
+func (h ctxHandler) Handle(ctx context.Context, r slog.Record) error {
+	*h.ctxs = append(*h.ctxs, ctx)
+	return nil
+}
+
+func TestLoggerSlogContext(t *testing.T) {
+	RegisterTestingT(t)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "v")
+	var ctxs []context.Context
+	l := &Logger{Slog: slog.New(ctxHandler{slog.NewTextHandler(&bytes.Buffer{}, nil), &ctxs})}
+
+	_, err := l.MapContext(NewContext(ctx), xml.CharData("x"))
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(ctxs).Should(HaveLen(1))
+	Ω(ctxs[0].Value(key{})).Should(Equal("v"))
+}
@@ -0,0 +1,173 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// XMLNamespace is the namespace URI bound to the reserved xml prefix.
+const XMLNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// nsTracker keeps track of the namespace declarations of processed tokens,
+// so the names can be resolved no matter whether a mapper runs before
+// NSNormalizer, i.e. names hold namespace URIs, or after it, i.e.
+// names hold prefixes in their local parts.
+type nsTracker struct {
+	ns NSStack
+}
+
+// isNSDecl reports whether an attribute is a namespace declaration,
+// and returns the declared prefix.
+func isNSDecl(a xml.Attr) (string, bool) {
+	switch {
+	case a.Name.Space == "xmlns":
+		return a.Name.Local, true
+	case a.Name.Space == "" && a.Name.Local == "xmlns":
+		return "", true
+	case a.Name.Space == "" && strings.HasPrefix(a.Name.Local, "xmlns:"):
+		return a.Name.Local[len("xmlns:"):], true
+	default:
+		return "", false
+	}
+}
+
+// isNormalized reports whether the names of a start tag are in the form
+// produced by NSNormalizer.
+func isNormalized(t xml.StartElement) bool {
+	if t.Name.Space != "" {
+		return false
+	}
+	for _, a := range t.Attr {
+		if a.Name.Space != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *nsTracker) push(start xml.StartElement) {
+	t.ns.Push()
+	for _, a := range start.Attr {
+		if prefix, ok := isNSDecl(a); ok {
+			t.ns.Set(prefix, a.Value)
+		}
+	}
+}
+
+func (t *nsTracker) pop() {
+	if len(t.ns) > 0 {
+		t.ns.Pop()
+	}
+}
+
+// lookup returns the namespace URI bound to a prefix.
+func (t *nsTracker) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return XMLNamespace, true
+	}
+	if p := t.ns.FindPrefix(prefix); p != nil {
+		return p.URI, true
+	}
+	return "", false
+}
+
+// bound reports whether a prefix is declared in the current scope.
+func (t *nsTracker) bound(prefix string) bool {
+	_, ok := t.lookup(prefix)
+	return ok
+}
+
+// uriPrefix returns a prefix bound to a namespace URI in the current scope.
+// A prefix redeclared by inner elements is not reported. The default
+// namespace is not reported for attribute names.
+func (t *nsTracker) uriPrefix(uri string, attr bool) (string, bool) {
+	if uri == XMLNamespace {
+		return "xml", true
+	}
+	for _, c := range t.ns {
+		for _, p := range c.Pairs {
+			if p.URI != uri || attr && p.Prefix == "" {
+				continue
+			}
+			if bound, _ := t.lookup(p.Prefix); bound == uri {
+				return p.Prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// declare binds a namespace URI to a prefix unused in the current scope,
+// and returns the declaration attribute. The prefix is taken from
+// the map of namespace URIs to the preferred prefixes, or generated.
+func (t *nsTracker) declare(uri string, prefixes map[string]string, normalized bool) xml.Attr {
+	prefix := prefixes[uri]
+	for i := 1; prefix == "" || t.bound(prefix); i++ {
+		prefix = "ns" + strconv.Itoa(i)
+	}
+	t.ns.Set(prefix, uri)
+
+	if normalized {
+		return xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: uri}
+	}
+	return xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: uri}
+}
+
+// resolve returns a name with the namespace URI in the Space field.
+// Unprefixed element names belong to the default namespace,
+// unprefixed attribute names have no namespace.
+func (t *nsTracker) resolve(name xml.Name, attr bool) xml.Name {
+	if name.Space != "" {
+		return name
+	}
+	if i := strings.IndexByte(name.Local, ':'); i >= 0 {
+		if uri, ok := t.lookup(name.Local[:i]); ok {
+			return xml.Name{Space: uri, Local: name.Local[i+1:]}
+		}
+		return xml.Name{Space: name.Local[:i], Local: name.Local[i+1:]}
+	}
+	if !attr {
+		if uri, ok := t.lookup(""); ok {
+			return xml.Name{Space: uri, Local: name.Local}
+		}
+	}
+	return name
+}
+
+// qualify converts a resolved name into the form of the processed tokens.
+// The names of normalized tokens get a prefix bound to the namespace URI,
+// if there's one.
+func (t *nsTracker) qualify(name xml.Name, attr, normalized bool) xml.Name {
+	if name.Space == "" || !normalized {
+		return name
+	}
+	if name.Space == XMLNamespace {
+		return xml.Name{Local: "xml:" + name.Local}
+	}
+	if prefix, ok := t.uriPrefix(name.Space, attr); ok {
+		if prefix == "" {
+			return xml.Name{Local: name.Local}
+		}
+		return xml.Name{Local: prefix + ":" + name.Local}
+	}
+	return name
+}
+
+// matchElement reports whether a start tag matches a name pattern and
+// a predicate, if set. The predicate gets the tag with the resolved name.
+func (t *nsTracker) matchElement(pattern xml.Name, fn func(xml.StartElement) bool, start xml.StartElement) bool {
+	start.Name = t.resolve(start.Name, false)
+	if !matchName(pattern, start.Name) {
+		return false
+	}
+	return fn == nil || fn(start)
+}
+
+// matchName reports whether a resolved name matches a pattern.
+// An empty Space of the pattern matches any namespace, and an empty Local
+// matches any local name.
+func matchName(pattern, name xml.Name) bool {
+	return (pattern.Space == "" || pattern.Space == name.Space) &&
+		(pattern.Local == "" || pattern.Local == name.Local)
+}
@@ -3,6 +3,8 @@ package mappers
 import (
 	"encoding/xml"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 type NSPair struct {
@@ -60,6 +62,20 @@ func (s *NSStack) Set(alias, space string) {
 	(*s)[0].Set(alias, space)
 }
 
+// boundPrefix returns a non-empty prefix bound to the URI in scope, i.e.
+// not redeclared by inner elements.
+func (s NSStack) boundPrefix(uri string) *NSPair {
+	for i := range s {
+		for j := range s[i].Pairs {
+			p := &s[i].Pairs[j]
+			if p.URI == uri && p.Prefix != "" && s.FindPrefix(p.Prefix) == p {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
 func (s *NSStack) Push() {
 	*s = append(NSStack{NSCollection{}}, (*s)...)
 }
@@ -73,21 +89,58 @@ func (s *NSStack) Pop() {
 // The mapper comes to fix the issues with XML namespaces in a standard
 // golang xml library.
 // See https://github.com/golang/go/search?q=namespace&type=Issues&utf8=%E2%9C%93
+//
+// Names in namespaces having no binding in scope, e.g. inserted by other
+// mappers, get generated prefixes (ns1, ns2 and so on, or the preferred
+// ones), declared on the elements using them.
+//
+// Prefixes, if set, maps namespace URIs to the preferred prefixes, e.g.
+// "stix" for http://stix.mitre.org/stix-1. The namespace declarations of
+// the listed URIs are rewritten to use the preferred prefixes; an empty
+// prefix makes a namespace the default one. A declaration using a preferred
+// prefix for another URI gets a new prefix, e.g. stix1.
+// NS holds the declarations as written to the output.
+//
+// The values of the attributes and the text of the elements listed in
+// QNames are qualified names, e.g. xsi:type="stixVocabs:Vocab-1.0"; their
+// prefixes are rewritten along with the declarations. QNames defaults to
+// XSIType, an empty non-nil list disables the rewriting.
 type NSNormalizer struct {
-	NS NSStack
+	NS       NSStack
+	Prefixes map[string]string
+	QNames   []xml.Name
+
+	in    nsTracker
+	qtext []bool
 }
 
+// XSIType is the name of the xsi:type attribute.
+var XSIType = xml.Name{Space: "http://www.w3.org/2001/XMLSchema-instance", Local: "type"}
+
 func (p NSNormalizer) SetNSAlias(name *xml.Name) {
+	p.setAlias(name, false)
+}
+
+// setAlias replaces the namespace of a name with a prefix bound in scope.
+// Unprefixed attributes have no namespace, so the default binding is used
+// for elements only.
+func (p NSNormalizer) setAlias(name *xml.Name, attr bool) {
 	if name.Space == "" {
 		return
 	}
 
-	if ns := p.NS.FindPrefix(""); ns != nil && ns.URI == name.Space {
+	if name.Space == XMLNamespace {
+		name.Local = "xml:" + name.Local
+		name.Space = ""
+		return
+	}
+
+	if ns := p.NS.FindPrefix(""); !attr && ns != nil && ns.URI == name.Space {
 		name.Space = ""
 		return
 	}
 
-	if ns := p.NS.FindURI(name.Space); ns != nil {
+	if ns := p.NS.boundPrefix(name.Space); ns != nil {
 		name.Local = ns.Prefix + ":" + name.Local
 		name.Space = ""
 		return
@@ -104,23 +157,52 @@ func (p *NSNormalizer) Map(t xml.Token) (xml.Token, error) {
 	switch token := t.(type) {
 	case xml.StartElement:
 		token = token.Copy()
+		p.in.push(token)
+		p.qtext = append(p.qtext, isQName(p.QNames, p.in.resolve(token.Name, false)))
 
 		p.NS.Push()
+		attrs := token.Attr[:0]
 		for _, a := range token.Attr {
-			if a.Name.Space == "xmlns" {
-				p.NS.Set(a.Name.Local, a.Value)
-			} else if a.Name.Space == "" && a.Name.Local == "xmlns" {
-				p.NS.Set("", a.Value)
+			prefix, ok := isNSDecl(a)
+			if !ok {
+				attrs = append(attrs, a)
+				continue
 			}
+
+			prefix = p.outputPrefix(prefix, a.Value)
+			if ns := p.NS[0].FindPrefix(prefix); ns != nil && ns.URI == a.Value {
+				// Several prefixes of the same URI are merged.
+				continue
+			}
+			p.NS.Set(prefix, a.Value)
+			if prefix == "" {
+				a.Name = xml.Name{Local: "xmlns"}
+			} else {
+				a.Name = xml.Name{Space: "xmlns", Local: prefix}
+			}
+			attrs = append(attrs, a)
 		}
+		token.Attr = attrs
+
+		token.Name = p.resolvePrefixed(token.Name)
+		for i := range token.Attr {
+			token.Attr[i].Name = p.resolvePrefixed(token.Attr[i].Name)
+		}
+		p.undeclareDefault(&token)
+		p.declareMissing(&token)
 
 		p.SetNSAlias(&token.Name)
 		for i := range token.Attr {
 			if token.Attr[i].Name.Space == "xmlns" {
 				token.Attr[i].Name.Space = ""
 				token.Attr[i].Name.Local = "xmlns:" + token.Attr[i].Name.Local
-			} else if token.Attr[i].Name.Space != "" {
-				p.SetNSAlias(&token.Attr[i].Name)
+				continue
+			}
+			if isQName(p.QNames, p.in.resolve(token.Attr[i].Name, true)) {
+				token.Attr[i].Value = p.requalify(token.Attr[i].Value)
+			}
+			if token.Attr[i].Name.Space != "" {
+				p.setAlias(&token.Attr[i].Name, true)
 			}
 		}
 
@@ -129,13 +211,171 @@ func (p *NSNormalizer) Map(t xml.Token) (xml.Token, error) {
 
 	case xml.EndElement:
 
+		token.Name = p.resolvePrefixed(token.Name)
 		p.SetNSAlias(&token.Name)
 		p.NS.Pop()
+		p.in.pop()
+		if len(p.qtext) > 0 {
+			p.qtext = p.qtext[:len(p.qtext)-1]
+		}
 
 		//          fmt.Printf("    end %#v\n", token)
 		return token, nil
 
+	case xml.CharData:
+		if len(p.qtext) == 0 || !p.qtext[len(p.qtext)-1] {
+			return t, nil
+		}
+		return xml.CharData(p.requalify(string(token))), nil
+
 	default:
 		return t, nil
 	}
 }
+
+// resolvePrefixed resolves a name in the prefixed form, e.g. produced by
+// a mapper expecting normalized tokens, so the prefix can be rewritten.
+// Names with undeclared prefixes are returned as is.
+func (p *NSNormalizer) resolvePrefixed(name xml.Name) xml.Name {
+	i := strings.IndexByte(name.Local, ':')
+	if name.Space != "" || i < 0 {
+		return name
+	}
+	if uri, ok := p.in.lookup(name.Local[:i]); ok {
+		return xml.Name{Space: uri, Local: name.Local[i+1:]}
+	}
+	return name
+}
+
+// isQName reports whether an attribute or an element holds a qualified
+// name, according to the list of names; XSIType is used if the list is nil.
+func isQName(names []xml.Name, name xml.Name) bool {
+	if names == nil {
+		return name == XSIType
+	}
+	for _, n := range names {
+		if matchName(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// requalify rewrites the prefix of a qualified name value according to
+// the output declarations. Values with unknown prefixes are kept as is.
+func (p *NSNormalizer) requalify(v string) string {
+	qname := strings.TrimSpace(v)
+	prefix, local := "", qname
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		prefix, local = qname[:i], qname[i+1:]
+	}
+	uri, ok := p.in.lookup(prefix)
+	if !ok || uri == "" {
+		return v
+	}
+
+	name := xml.Name{Space: uri, Local: local}
+	p.SetNSAlias(&name)
+	if name.Space != "" {
+		return v
+	}
+	return strings.Replace(v, qname, name.Local, 1)
+}
+
+// undeclareDefault adds xmlns="" to an element having no namespace, when
+// a preferred empty prefix made another namespace the default one in scope.
+func (p *NSNormalizer) undeclareDefault(t *xml.StartElement) {
+	if p.Prefixes == nil || t.Name.Space != "" || strings.IndexByte(t.Name.Local, ':') >= 0 {
+		return
+	}
+	if ns := p.NS.FindPrefix(""); ns != nil && ns.URI != "" {
+		p.NS.Set("", "")
+		t.Attr = append(t.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: ""})
+	}
+}
+
+// declareMissing declares generated prefixes, e.g. ns1, for the namespaces
+// of the names having no binding in scope. A prefix listed in Prefixes is
+// used, if it's not bound yet.
+func (p *NSNormalizer) declareMissing(t *xml.StartElement) {
+	names := []xml.Name{t.Name}
+	for _, a := range t.Attr {
+		if a.Name.Space != "xmlns" {
+			names = append(names, a.Name)
+		}
+	}
+
+	for i, n := range names {
+		if !p.unbound(n.Space, i > 0) {
+			continue
+		}
+		prefix, ok := p.Prefixes[n.Space]
+		if ok && prefix == "" && i == 0 {
+			// The element name gets the preferred default namespace.
+			p.NS.Set("", n.Space)
+			t.Attr = append(t.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: n.Space})
+			continue
+		}
+		for i := 1; prefix == "" || p.NS.FindPrefix(prefix) != nil; i++ {
+			prefix = "ns" + strconv.Itoa(i)
+		}
+		p.NS.Set(prefix, n.Space)
+		t.Attr = append(t.Attr, xml.Attr{
+			Name:  xml.Name{Space: "xmlns", Local: prefix},
+			Value: n.Space,
+		})
+	}
+}
+
+// unbound reports whether a namespace URI of a name has no binding in scope,
+// the default binding is not used for attributes. Letters-only URIs are left
+// for SetNSAlias, as the decoder puts there the undeclared prefixes.
+func (p *NSNormalizer) unbound(uri string, attr bool) bool {
+	if uri == "" || uri == XMLNamespace || uri == "xmlns" || p.NS.boundPrefix(uri) != nil {
+		return false
+	}
+	if ns := p.NS.FindPrefix(""); !attr && ns != nil && ns.URI == uri {
+		return false
+	}
+	ok, _ := regexp.MatchString(`[^a-zA-Z]`, uri)
+	return ok
+}
+
+// outputPrefix returns the prefix a namespace declaration is written with.
+func (p *NSNormalizer) outputPrefix(prefix, uri string) string {
+	if p.Prefixes == nil || uri == "" {
+		// Undeclarations, i.e. xmlns="", are never renamed.
+		return prefix
+	}
+	free := func(prefix string) bool {
+		ns := p.NS[0].FindPrefix(prefix)
+		return ns == nil || ns.URI == uri
+	}
+	if preferred, ok := p.Prefixes[uri]; ok && free(preferred) {
+		return preferred
+	}
+	if !p.reserved(prefix, uri) && free(prefix) {
+		return prefix
+	}
+
+	base := prefix
+	if base == "" {
+		base = "ns"
+	}
+	for i := 1; ; i++ {
+		alias := base + strconv.Itoa(i)
+		if !p.reserved(alias, uri) && free(alias) {
+			return alias
+		}
+	}
+}
+
+// reserved reports whether a prefix is preferred for another namespace URI.
+func (p *NSNormalizer) reserved(prefix, uri string) bool {
+	for u, preferred := range p.Prefixes {
+		if preferred == prefix && u != uri {
+			return true
+		}
+	}
+	return false
+}
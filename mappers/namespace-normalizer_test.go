@@ -187,10 +187,14 @@ func TestNSNormalizerEmptyStack(t *testing.T) {
 	Ω(err).ShouldNot(HaveOccurred())
 	Ω(res).Should(Equal(xml.StartElement{
 		Name: xml.Name{
-			Space: "http://www.w3.org/2000/svg",
-			Local: "g",
+			Local: "ns1:g",
+		},
+		Attr: []xml.Attr{
+			xml.Attr{
+				Name:  xml.Name{Local: "xmlns:ns1"},
+				Value: "http://www.w3.org/2000/svg",
+			},
 		},
-		Attr: []xml.Attr{},
 	}))
 
 	res, err = ns.Map(xml.EndElement{
@@ -202,8 +206,7 @@ func TestNSNormalizerEmptyStack(t *testing.T) {
 	Ω(err).ShouldNot(HaveOccurred())
 	Ω(res).Should(Equal(xml.EndElement{
 		Name: xml.Name{
-			Space: "http://www.w3.org/2000/svg",
-			Local: "g",
+			Local: "ns1:g",
 		},
 	}))
 
@@ -332,3 +335,119 @@ func TestNSNormalizerNSAttributes(t *testing.T) {
 		},
 	}))
 }
+
+func TestNSNormalizerPrefixes(t *testing.T) {
+	RegisterTestingT(t)
+
+	prefixes := map[string]string{
+		"http://stix.mitre.org/stix-1": "stix",
+		"urn:default":                  "",
+	}
+
+	for _, tc := range []struct {
+		src string
+		res string
+	}{
+		{
+			`<s:STIX_Package xmlns:s="http://stix.mitre.org/stix-1" s:id="1"><s:Title>t</s:Title></s:STIX_Package>`,
+			`<stix:STIX_Package xmlns:stix="http://stix.mitre.org/stix-1" stix:id="1"><stix:Title>t</stix:Title></stix:STIX_Package>`,
+		},
+		{
+			`<STIX_Package xmlns="http://stix.mitre.org/stix-1"><Title>t</Title></STIX_Package>`,
+			`<stix:STIX_Package xmlns:stix="http://stix.mitre.org/stix-1"><stix:Title>t</stix:Title></stix:STIX_Package>`,
+		},
+		{
+			`<stix:a xmlns:stix="urn:other" xmlns:s="http://stix.mitre.org/stix-1"><s:b></s:b><stix:c></stix:c></stix:a>`,
+			`<stix1:a xmlns:stix1="urn:other" xmlns:stix="http://stix.mitre.org/stix-1"><stix:b></stix:b><stix1:c></stix1:c></stix1:a>`,
+		},
+		{
+			`<a xmlns="urn:other"><d:b xmlns:d="urn:default"><d:c></d:c></d:b></a>`,
+			`<ns1:a xmlns:ns1="urn:other"><b xmlns="urn:default"><c></c></b></ns1:a>`,
+		},
+		{
+			`<a xmlns:x="http://stix.mitre.org/stix-1" xmlns:y="http://stix.mitre.org/stix-1"><x:b></x:b><y:c></y:c></a>`,
+			`<a xmlns:stix="http://stix.mitre.org/stix-1"><stix:b></stix:b><stix:c></stix:c></a>`,
+		},
+	} {
+		Ω(process(tc.src, &NSNormalizer{Prefixes: prefixes})).Should(Equal(tc.res))
+	}
+}
+
+func TestNSNormalizerQNames(t *testing.T) {
+	RegisterTestingT(t)
+
+	const src = `<r xmlns:s="http://stix.mitre.org/stix-1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:v="urn:vocabs">` +
+		`<s:Intent xsi:type="v:IntentVocab-1.0">x</s:Intent>` +
+		`<s:Type xsi:type="s:Type">s:Value</s:Type>` +
+		`<s:Other xsi:type="u:Unknown"> s:Value </s:Other></r>`
+	prefixes := map[string]string{
+		"http://stix.mitre.org/stix-1": "stix",
+		"urn:vocabs":                   "stixVocabs",
+	}
+
+	Ω(process(src, &NSNormalizer{Prefixes: prefixes})).Should(Equal(
+		`<r xmlns:stix="http://stix.mitre.org/stix-1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:stixVocabs="urn:vocabs">` +
+			`<stix:Intent xsi:type="stixVocabs:IntentVocab-1.0">x</stix:Intent>` +
+			`<stix:Type xsi:type="stix:Type">s:Value</stix:Type>` +
+			`<stix:Other xsi:type="u:Unknown"> s:Value </stix:Other></r>`))
+
+	m := &NSNormalizer{
+		Prefixes: prefixes,
+		QNames:   []xml.Name{{Space: "http://stix.mitre.org/stix-1", Local: "Type"}, {Local: "Other"}},
+	}
+	Ω(process(src, m)).Should(Equal(
+		`<r xmlns:stix="http://stix.mitre.org/stix-1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:stixVocabs="urn:vocabs">` +
+			`<stix:Intent xsi:type="v:IntentVocab-1.0">x</stix:Intent>` +
+			`<stix:Type xsi:type="s:Type">stix:Value</stix:Type>` +
+			`<stix:Other xsi:type="u:Unknown"> stix:Value </stix:Other></r>`))
+}
+
+func TestNSNormalizerUndeclaredNamespaces(t *testing.T) {
+	RegisterTestingT(t)
+
+	ins := AppendChild(xml.Name{Local: "r"},
+		xml.StartElement{
+			Name: xml.Name{Space: "urn:z", Local: "c"},
+			Attr: []xml.Attr{
+				{Name: xml.Name{Space: "urn:w", Local: "id"}, Value: "1"},
+				{Name: xml.Name{Space: XMLNamespace, Local: "lang"}, Value: "en"},
+			},
+		},
+		xml.StartElement{Name: xml.Name{Space: "urn:y", Local: "d"}},
+		xml.EndElement{Name: xml.Name{Space: "urn:y", Local: "d"}},
+		xml.EndElement{Name: xml.Name{Space: "urn:z", Local: "c"}},
+	)
+
+	Ω(process(`<r xmlns:ns1="urn:n"></r>`, ins, &NSNormalizer{Prefixes: map[string]string{"urn:y": "y"}})).Should(Equal(
+		`<r xmlns:ns1="urn:n"><ns2:c ns3:id="1" xml:lang="en" xmlns:ns2="urn:z" xmlns:ns3="urn:w">` +
+			`<y:d xmlns:y="urn:y"></y:d></ns2:c></r>`))
+}
+
+func TestNSNormalizerRenamedToUndeclared(t *testing.T) {
+	RegisterTestingT(t)
+
+	r := &Renamer{Names: map[xml.Name]xml.Name{
+		{Local: "a"}: {Space: "urn:x", Local: "a"},
+	}}
+	Ω(process(`<r><a></a></r>`, r, &NSNormalizer{Prefixes: map[string]string{"urn:x": "x"}})).
+		Should(Equal(`<r><x:a xmlns:x="urn:x"></x:a></r>`))
+}
+
+func TestNSNormalizerPreferredDefault(t *testing.T) {
+	RegisterTestingT(t)
+
+	Ω(process(`<p:r xmlns:p="urn:u"><c><p:d></p:d></c></p:r>`, &NSNormalizer{Prefixes: map[string]string{"urn:u": ""}})).
+		Should(Equal(`<r xmlns="urn:u"><c xmlns=""><d xmlns="urn:u"></d></c></r>`))
+	Ω(process(`<r xmlns="urn:u"><a xmlns=""></a></r>`, &NSNormalizer{Prefixes: map[string]string{"urn:u2": ""}})).
+		Should(Equal(`<ns1:r xmlns:ns1="urn:u"><a xmlns=""></a></ns1:r>`))
+}
+
+func TestNSNormalizerDefaultNSAttributes(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := SetAttr(xml.Name{Space: "urn:u", Local: "r"}, xml.Name{Space: "urn:u", Local: "x"}, "1")
+	Ω(process(`<r xmlns="urn:u"></r>`, m, &NSNormalizer{})).
+		Should(Equal(`<r xmlns="urn:u" ns1:x="1" xmlns:ns1="urn:u"></r>`))
+	Ω(process(`<r xmlns="urn:u" xmlns:p="urn:u" p:x="1"></r>`, &NSNormalizer{})).
+		Should(Equal(`<r xmlns="urn:u" xmlns:p="urn:u" p:x="1"></r>`))
+}
@@ -0,0 +1,169 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// NSHoister moves the namespace declarations of a document to the root
+// element, so the repeated declarations are written once.
+// If a prefix is declared for different namespace URIs, or a namespace is
+// declared with different prefixes, the prefixes of the names and
+// QName-valued content (see NSNormalizer.QNames) are rewritten.
+// Default namespace declarations are kept in place.
+// The root element is buffered until its end tag, i.e. the whole document
+// is kept in memory. The mapper works both before and after NSNormalizer.
+type NSHoister struct {
+	QNames []xml.Name
+
+	buf   []xml.Token
+	depth int
+}
+
+func (m *NSHoister) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapTokens(t))
+}
+
+func (m *NSHoister) MapTokens(t xml.Token) ([]xml.Token, error) {
+	switch t.(type) {
+	case xml.StartElement:
+		m.depth++
+	case xml.EndElement:
+		if m.depth == 0 {
+			return []xml.Token{t}, nil
+		}
+		m.depth--
+	default:
+		if m.depth == 0 {
+			return []xml.Token{t}, nil
+		}
+	}
+
+	m.buf = append(m.buf, t)
+	if m.depth > 0 {
+		return nil, nil
+	}
+
+	res := m.hoist(m.buf)
+	m.buf = nil
+	return res, nil
+}
+
+// hoist moves the declarations of a buffered element to the element.
+func (m *NSHoister) hoist(ts []xml.Token) []xml.Token {
+	root := ts[0].(xml.StartElement).Copy()
+	prefixes := map[string]string{}
+	uris := map[string]string{}
+	for _, a := range root.Attr {
+		if prefix, ok := isNSDecl(a); ok && prefix != "" {
+			prefixes[prefix] = a.Value
+			if _, ok := uris[a.Value]; !ok {
+				uris[a.Value] = prefix
+			}
+		}
+	}
+
+	var ns nsTracker
+	ns.push(root)
+	var renames []map[string]string
+	var qtext []bool
+	rename := func(prefix string) string {
+		for i := len(renames) - 1; i >= 0; i-- {
+			if p, ok := renames[i][prefix]; ok {
+				return p
+			}
+		}
+		return prefix
+	}
+	renameName := func(name xml.Name) xml.Name {
+		if name.Space != "" {
+			return name
+		}
+		if i := strings.IndexByte(name.Local, ':'); i > 0 {
+			name.Local = rename(name.Local[:i]) + name.Local[i:]
+		}
+		return name
+	}
+	renameQName := func(v string) string {
+		qname := strings.TrimSpace(v)
+		if i := strings.IndexByte(qname, ':'); i > 0 {
+			return strings.Replace(v, qname, rename(qname[:i])+qname[i:], 1)
+		}
+		return v
+	}
+
+	res := []xml.Token{nil}
+	for _, t := range ts[1 : len(ts)-1] {
+		switch token := t.(type) {
+		case xml.StartElement:
+			ns.push(token)
+			qtext = append(qtext, isQName(m.QNames, ns.resolve(token.Name, false)))
+			token = token.Copy()
+			frame := map[string]string{}
+
+			attrs := token.Attr[:0]
+			for _, a := range token.Attr {
+				prefix, ok := isNSDecl(a)
+				if !ok || prefix == "" {
+					attrs = append(attrs, a)
+					continue
+				}
+
+				out, ok := uris[a.Value]
+				if !ok {
+					out = prefix
+					for i := 1; prefixes[out] != ""; i++ {
+						out = prefix + strconv.Itoa(i)
+					}
+					prefixes[out] = a.Value
+					uris[a.Value] = out
+					root.Attr = append(root.Attr, declAttr(a, out))
+				}
+				frame[prefix] = out
+			}
+			token.Attr = attrs
+			renames = append(renames, frame)
+
+			token.Name = renameName(token.Name)
+			for i, a := range token.Attr {
+				if _, ok := isNSDecl(a); ok {
+					continue
+				}
+				if isQName(m.QNames, ns.resolve(a.Name, true)) {
+					token.Attr[i].Value = renameQName(a.Value)
+				}
+				token.Attr[i].Name = renameName(a.Name)
+			}
+			res = append(res, token)
+
+		case xml.EndElement:
+			token.Name = renameName(token.Name)
+			renames = renames[:len(renames)-1]
+			qtext = qtext[:len(qtext)-1]
+			ns.pop()
+			res = append(res, token)
+
+		case xml.CharData:
+			if len(qtext) > 0 && qtext[len(qtext)-1] {
+				token = xml.CharData(renameQName(string(token)))
+			}
+			res = append(res, token)
+
+		default:
+			res = append(res, t)
+		}
+	}
+
+	res[0] = root
+	return append(res, ts[len(ts)-1])
+}
+
+// declAttr returns a namespace declaration of the prefix, in the same form
+// as the given declaration.
+func declAttr(a xml.Attr, prefix string) xml.Attr {
+	if a.Name.Space == "xmlns" {
+		return xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: a.Value}
+	}
+	return xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: a.Value}
+}
@@ -0,0 +1,29 @@
+package mappers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestNSHoister(t *testing.T) {
+	RegisterTestingT(t)
+
+	const src = `<!--c--><r xmlns:a="urn:a" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<x xmlns:b="urn:b"><b:y b:id="1"></b:y></x>` +
+		`<x xmlns:b="urn:b"><b:y xmlns:c="urn:c" xsi:type="c:T"></b:y></x>` +
+		`<x xmlns:aa="urn:a"><aa:y></aa:y></x>` +
+		`<x xmlns:a="urn:a2"><a:y xsi:type="a:T"></a:y></x>` +
+		`<x xmlns="urn:d"><y></y></x>` +
+		`</r>`
+	const res = `<!--c--><r xmlns:a="urn:a" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:b="urn:b" xmlns:c="urn:c" xmlns:a1="urn:a2">` +
+		`<x><b:y b:id="1"></b:y></x>` +
+		`<x><b:y xsi:type="c:T"></b:y></x>` +
+		`<x><a:y></a:y></x>` +
+		`<x><a1:y xsi:type="a1:T"></a1:y></x>` +
+		`<x xmlns="urn:d"><y></y></x>` +
+		`</r>`
+
+	Ω(process(src, &NSNormalizer{}, &NSHoister{})).Should(Equal(res))
+	Ω(process(src, &NSHoister{}, &NSNormalizer{})).Should(Equal(res))
+}
@@ -0,0 +1,195 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// NSPruner removes namespace declarations not used by any element,
+// attribute or QName-valued content in their scope.
+// The elements declaring namespaces are buffered until their end tags,
+// so usually the whole document is kept in memory.
+// The mapper works both before and after NSNormalizer.
+type NSPruner struct {
+	// Keep lists the namespace URIs whose declarations are never removed.
+	Keep []string
+	// QNames lists the attributes and elements holding qualified names,
+	// see NSNormalizer.QNames.
+	QNames []xml.Name
+
+	buf   []xml.Token
+	depth int
+}
+
+// nsDecl is a namespace declaration found in the buffered tokens.
+type nsDecl struct {
+	token  int
+	attr   int
+	prefix string
+	uri    string
+	used   bool
+}
+
+func (m *NSPruner) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapTokens(t))
+}
+
+func (m *NSPruner) MapTokens(t xml.Token) ([]xml.Token, error) {
+	switch token := t.(type) {
+	case xml.StartElement:
+		if m.depth == 0 && len(nsDecls(token.Attr)) == 0 {
+			return []xml.Token{t}, nil
+		}
+		m.depth++
+	case xml.EndElement:
+		if m.depth == 0 {
+			return []xml.Token{t}, nil
+		}
+		m.depth--
+	default:
+		if m.depth == 0 {
+			return []xml.Token{t}, nil
+		}
+	}
+
+	m.buf = append(m.buf, t)
+	if m.depth > 0 {
+		return nil, nil
+	}
+
+	res := m.prune(m.buf)
+	m.buf = nil
+	return res, nil
+}
+
+// prune removes the unused declarations from a buffered element.
+func (m *NSPruner) prune(ts []xml.Token) []xml.Token {
+	// decls holds all the declarations, active the ones in scope.
+	var decls, active []*nsDecl
+	var scopes []int
+	var ns nsTracker
+	var qtext []bool
+
+	// binding returns the innermost declaration of a prefix in scope.
+	binding := func(prefix string) *nsDecl {
+		for i := len(active) - 1; i >= 0; i-- {
+			if active[i].prefix == prefix {
+				return active[i]
+			}
+		}
+		return nil
+	}
+	usePrefix := func(prefix string) {
+		if d := binding(prefix); d != nil {
+			d.used = true
+		}
+	}
+	// useURI marks the declaration a name in the namespace is written with,
+	// the same way NSNormalizer chooses it: the default namespace for
+	// elements, or the innermost prefix not redeclared in scope.
+	useURI := func(uri string, attr bool) {
+		if d := binding(""); !attr && d != nil && d.uri == uri {
+			d.used = true
+			return
+		}
+		var found *nsDecl
+		for _, d := range active {
+			if d.uri == uri && d.prefix != "" && binding(d.prefix) == d && (found == nil || d.token > found.token) {
+				found = d
+			}
+		}
+		if found != nil {
+			found.used = true
+		}
+	}
+	use := func(name xml.Name, attr bool) {
+		switch {
+		case name.Space == XMLNamespace || strings.HasPrefix(name.Local, "xml:"):
+		case name.Space != "":
+			useURI(name.Space, attr)
+		case strings.IndexByte(name.Local, ':') >= 0:
+			usePrefix(name.Local[:strings.IndexByte(name.Local, ':')])
+		case !attr:
+			usePrefix("")
+		}
+	}
+	useQName := func(v string) {
+		v = strings.TrimSpace(v)
+		if i := strings.IndexByte(v, ':'); i >= 0 {
+			usePrefix(v[:i])
+		} else if v != "" {
+			usePrefix("")
+		}
+	}
+
+	for i, t := range ts {
+		switch token := t.(type) {
+		case xml.StartElement:
+			ns.push(token)
+			scopes = append(scopes, len(active))
+			for j, a := range token.Attr {
+				if prefix, ok := isNSDecl(a); ok {
+					d := &nsDecl{token: i, attr: j, prefix: prefix, uri: a.Value}
+					decls = append(decls, d)
+					active = append(active, d)
+				}
+			}
+
+			use(token.Name, false)
+			for _, a := range token.Attr {
+				if _, ok := isNSDecl(a); ok {
+					continue
+				}
+				use(a.Name, true)
+				if isQName(m.QNames, ns.resolve(a.Name, true)) {
+					useQName(a.Value)
+				}
+			}
+			qtext = append(qtext, isQName(m.QNames, ns.resolve(token.Name, false)))
+
+		case xml.EndElement:
+			ns.pop()
+			qtext = qtext[:len(qtext)-1]
+			active = active[:scopes[len(scopes)-1]]
+			scopes = scopes[:len(scopes)-1]
+
+		case xml.CharData:
+			if len(qtext) > 0 && qtext[len(qtext)-1] {
+				useQName(string(token))
+			}
+		}
+	}
+
+	unused := map[int]map[int]bool{}
+	for _, d := range decls {
+		if d.used || m.keep(d.uri) {
+			continue
+		}
+		if unused[d.token] == nil {
+			unused[d.token] = map[int]bool{}
+		}
+		unused[d.token][d.attr] = true
+	}
+
+	for i, attrs := range unused {
+		start := ts[i].(xml.StartElement)
+		var kept []xml.Attr
+		for j, a := range start.Attr {
+			if !attrs[j] {
+				kept = append(kept, a)
+			}
+		}
+		start.Attr = kept
+		ts[i] = start
+	}
+	return ts
+}
+
+func (m *NSPruner) keep(uri string) bool {
+	for _, u := range m.Keep {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestNSPruner(t *testing.T) {
+	RegisterTestingT(t)
+
+	const src = `<?xml version="1.0"?>` +
+		`<r xmlns="urn:r" xmlns:a="urn:a" xmlns:b="urn:b" xmlns:c="urn:c" xmlns:v="urn:v" xmlns:k="urn:k" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<a:x b:id="1" xsi:type="v:T"></a:x>` +
+		`<y xmlns:c="urn:c2" xmlns:d="urn:d"><c:z></c:z></y>` +
+		`</r>`
+	const res = `<?xml version="1.0"?>` +
+		`<r xmlns="urn:r" xmlns:a="urn:a" xmlns:b="urn:b" xmlns:v="urn:v" xmlns:k="urn:k" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<a:x b:id="1" xsi:type="v:T"></a:x>` +
+		`<y xmlns:c="urn:c2"><c:z></c:z></y>` +
+		`</r>`
+
+	Ω(process(src, &NSPruner{Keep: []string{"urn:k"}}, &NSNormalizer{})).Should(Equal(res))
+	Ω(process(src, &NSNormalizer{}, &NSPruner{Keep: []string{"urn:k"}})).Should(Equal(res))
+}
+
+func TestNSPrunerQNameText(t *testing.T) {
+	RegisterTestingT(t)
+
+	const src = `<r xmlns:a="urn:a" xmlns:b="urn:b"><code>a:Server</code><b:x/></r><c/>`
+
+	Ω(process(src, &NSNormalizer{}, &NSPruner{})).
+		Should(Equal(`<r xmlns:b="urn:b"><code>a:Server</code><b:x></b:x></r><c></c>`))
+	Ω(process(src, &NSNormalizer{}, &NSPruner{QNames: []xml.Name{{Local: "code"}}})).
+		Should(Equal(`<r xmlns:a="urn:a" xmlns:b="urn:b"><code>a:Server</code><b:x></b:x></r><c></c>`))
+}
+
+func TestNSPrunerBinding(t *testing.T) {
+	RegisterTestingT(t)
+
+	const src = `<r xmlns="urn:u"><p:a xmlns:p="urn:u"><b></b></p:a><c></c></r>`
+
+	Ω(process(src, &NSPruner{}, &NSNormalizer{})).
+		Should(Equal(`<r xmlns="urn:u"><a><b></b></a><c></c></r>`))
+	Ω(process(src, &NSNormalizer{}, &NSPruner{})).
+		Should(Equal(`<r xmlns="urn:u"><a><b></b></a><c></c></r>`))
+}
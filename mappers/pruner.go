@@ -2,23 +2,102 @@ package mappers
 
 import (
 	"encoding/xml"
+	"regexp"
 	"strings"
 )
 
-// Pruner removes all XML comments (xml.Comment) and
+// PruneKind is a set of kinds of tokens removed by Pruner.
+type PruneKind int
+
+const (
+	// PruneComments removes comments (xml.Comment).
+	PruneComments PruneKind = 1 << iota
+	// PruneWhitespace removes chardata (xml.CharData) consisting of
+	// whitespaces only.
+	PruneWhitespace
+	// PruneProcInsts removes processing instructions (xml.ProcInst),
+	// except for the XML declaration.
+	PruneProcInsts
+	// PruneDirectives removes directives (xml.Directive), e.g. DOCTYPE.
+	PruneDirectives
+)
+
+// Pruner removes tokens not affecting the content of a document.
+// The zero value removes all XML comments (xml.Comment) and
 // any chardata (xml.CharData), consisting of whitespaces only.
-type Pruner struct{}
+// Whitespace is kept in the scope of xml:space="preserve" attributes,
+// which are only known when the mapper runs as a context-aware mapper,
+// e.g. in xmlproc.Processor.
+type Pruner struct {
+	// Kinds of the removed tokens, PruneComments|PruneWhitespace if zero.
+	Kinds PruneKind
+	// KeepComments lists the patterns of comments to keep, e.g. license
+	// headers.
+	KeepComments []*regexp.Regexp
+	// IgnoreXMLSpace makes the pruner remove whitespace regardless of
+	// xml:space attributes.
+	IgnoreXMLSpace bool
+}
+
+var xmlSpace = xml.Name{Space: XMLNamespace, Local: "space"}
 
 func (p Pruner) Map(t xml.Token) (xml.Token, error) {
+	return Single(p.MapContext(&Context{}, t))
+}
+
+func (p Pruner) MapContext(c *Context, t xml.Token) ([]xml.Token, error) {
+	kinds := p.Kinds
+	if kinds == 0 {
+		kinds = PruneComments | PruneWhitespace
+	}
+
 	switch token := t.(type) {
 	case xml.Comment:
+		if kinds&PruneComments == 0 || p.keep(token) {
+			return []xml.Token{t}, nil
+		}
 		return nil, nil
 	case xml.CharData:
-		if strings.TrimSpace(string(token)) == "" {
-			return nil, nil
+		if kinds&PruneWhitespace == 0 || strings.TrimSpace(string(token)) != "" || p.preserved(c.Path) {
+			return []xml.Token{t}, nil
+		}
+		return nil, nil
+	case xml.ProcInst:
+		if kinds&PruneProcInsts == 0 || token.Target == "xml" {
+			return []xml.Token{t}, nil
 		}
-		return t, nil
+		return nil, nil
+	case xml.Directive:
+		if kinds&PruneDirectives == 0 {
+			return []xml.Token{t}, nil
+		}
+		return nil, nil
 	default:
-		return t, nil
+		return []xml.Token{t}, nil
+	}
+}
+
+// preserved reports whether the innermost xml:space attribute of the path
+// elements is "preserve".
+func (p Pruner) preserved(path Path) bool {
+	if p.IgnoreXMLSpace {
+		return false
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		for _, a := range path[i].Attr {
+			if a.Name == xmlSpace || a.Name.Space == "" && a.Name.Local == "xml:space" {
+				return a.Value == "preserve"
+			}
+		}
+	}
+	return false
+}
+
+func (p Pruner) keep(c xml.Comment) bool {
+	for _, re := range p.KeepComments {
+		if re.Match(c) {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,40 @@
+package mappers
+
+import (
+	"regexp"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPruner(t *testing.T) {
+	RegisterTestingT(t)
+
+	const doc = `<?xml version="1.0"?><!DOCTYPE r><?pi x?><!-- License: MIT --><r>
+  <!-- note --><a xml:space="preserve"> <b> </b><c xml:space="default"> </c></a> </r>`
+
+	for _, tc := range []struct {
+		pruner *Pruner
+		res    string
+	}{
+		{
+			&Pruner{},
+			`<?xml version="1.0"?><!DOCTYPE r><?pi x?><r><a xml:space="preserve"> <b> </b><c xml:space="default"></c></a></r>`,
+		},
+		{
+			&Pruner{IgnoreXMLSpace: true},
+			`<?xml version="1.0"?><!DOCTYPE r><?pi x?><r><a xml:space="preserve"><b></b><c xml:space="default"></c></a></r>`,
+		},
+		{
+			&Pruner{
+				Kinds:        PruneComments | PruneProcInsts | PruneDirectives,
+				KeepComments: []*regexp.Regexp{regexp.MustCompile(`License`)},
+			},
+			`<?xml version="1.0"?><!-- License: MIT --><r>
+  <a xml:space="preserve"> <b> </b><c xml:space="default"> </c></a> </r>`,
+		},
+	} {
+		Ω(process(doc, tc.pruner, &NSNormalizer{})).Should(Equal(tc.res))
+		Ω(process(doc, *tc.pruner, &NSNormalizer{})).Should(Equal(tc.res))
+	}
+}
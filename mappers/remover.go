@@ -0,0 +1,129 @@
+package mappers
+
+import "encoding/xml"
+
+// Remover is a mapper removing elements together with their content.
+// In the unwrap mode only the start and end tags of an element are removed,
+// while the content is kept in the parent element; the namespace
+// declarations of the removed element are moved to the child elements.
+//
+// Elements are matched by Name, similarly to Attr, and by Func, if set.
+// Func gets the start tag with the name resolved to the namespace URI.
+// Use RemoveElement and UnwrapElement to create the mapper.
+type Remover struct {
+	Name   xml.Name
+	Func   func(xml.StartElement) bool
+	Unwrap bool
+
+	ns nsTracker
+	// skip is the depth of the removed subtree being skipped, if any.
+	skip  int
+	stack []removed
+}
+
+// removed describes an open element.
+type removed struct {
+	unwrapped bool
+	// decls are the namespace declarations to be moved to the children.
+	decls []xml.Attr
+}
+
+// RemoveElement removes the elements with the given name and their content.
+func RemoveElement(name xml.Name) *Remover {
+	return &Remover{Name: name}
+}
+
+// UnwrapElement removes the elements with the given name, keeping their
+// content.
+func UnwrapElement(name xml.Name) *Remover {
+	return &Remover{Name: name, Unwrap: true}
+}
+
+func (m *Remover) Map(t xml.Token) (xml.Token, error) {
+	switch token := t.(type) {
+	case xml.StartElement:
+		if m.skip > 0 {
+			m.skip++
+			return nil, nil
+		}
+
+		m.ns.push(token)
+		var inherited []xml.Attr
+		if n := len(m.stack); n > 0 && m.stack[n-1].unwrapped {
+			inherited = m.stack[n-1].decls
+		}
+
+		if m.ns.matchElement(m.Name, m.Func, token) {
+			if !m.Unwrap {
+				m.ns.pop()
+				m.skip = 1
+				return nil, nil
+			}
+			m.stack = append(m.stack, removed{
+				unwrapped: true,
+				decls:     mergeDecls(nsDecls(token.Attr), inherited),
+			})
+			return nil, nil
+		}
+
+		m.stack = append(m.stack, removed{})
+		if len(inherited) > 0 {
+			token = token.Copy()
+			token.Attr = mergeDecls(token.Attr, inherited)
+		}
+		return token, nil
+
+	case xml.EndElement:
+		if m.skip > 0 {
+			m.skip--
+			return nil, nil
+		}
+
+		m.ns.pop()
+		if len(m.stack) == 0 {
+			return t, nil
+		}
+		top := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		if top.unwrapped {
+			return nil, nil
+		}
+		return t, nil
+
+	default:
+		if m.skip > 0 {
+			return nil, nil
+		}
+		return t, nil
+	}
+}
+
+// nsDecls returns the namespace declarations among the attributes.
+func nsDecls(attrs []xml.Attr) []xml.Attr {
+	var res []xml.Attr
+	for _, a := range attrs {
+		if _, ok := isNSDecl(a); ok {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// mergeDecls adds the namespace declarations missing in the attributes,
+// in front of them.
+func mergeDecls(attrs, decls []xml.Attr) []xml.Attr {
+	declared := map[string]bool{}
+	for _, a := range attrs {
+		if prefix, ok := isNSDecl(a); ok {
+			declared[prefix] = true
+		}
+	}
+
+	var res []xml.Attr
+	for _, a := range decls {
+		if prefix, _ := isNSDecl(a); !declared[prefix] {
+			res = append(res, a)
+		}
+	}
+	return append(res, attrs...)
+}
@@ -0,0 +1,37 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRemover(t *testing.T) {
+	RegisterTestingT(t)
+
+	const doc = `<r xmlns:p="urn:p"><p:a><b>x</b><p:a>y</p:a></p:a><b id="1"></b><b id="2"></b></r>`
+
+	Ω(process(doc, RemoveElement(elemA), &NSNormalizer{})).
+		Should(Equal(`<r xmlns:p="urn:p"><b id="1"></b><b id="2"></b></r>`))
+	Ω(process(doc, &NSNormalizer{}, RemoveElement(elemA))).
+		Should(Equal(`<r xmlns:p="urn:p"><b id="1"></b><b id="2"></b></r>`))
+	Ω(process(doc, UnwrapElement(elemA), &NSNormalizer{})).
+		Should(Equal(`<r xmlns:p="urn:p"><b>x</b>y<b id="1"></b><b id="2"></b></r>`))
+
+	m := &Remover{Name: xml.Name{Local: "b"}, Func: func(t xml.StartElement) bool {
+		return len(t.Attr) > 0 && t.Attr[0].Value == "2"
+	}}
+	Ω(process(doc, m, &NSNormalizer{})).
+		Should(Equal(`<r xmlns:p="urn:p"><p:a><b>x</b><p:a>y</p:a></p:a><b id="1"></b></r>`))
+}
+
+func TestRemoverUnwrapMovesDeclarations(t *testing.T) {
+	RegisterTestingT(t)
+
+	const doc = `<r><w xmlns:p="urn:p" id="1"><p:a></p:a>x<w><p:b p:id="2"></p:b></w></w></r>`
+	const res = `<r><p:a xmlns:p="urn:p"></p:a>x<p:b xmlns:p="urn:p" p:id="2"></p:b></r>`
+
+	Ω(process(doc, UnwrapElement(xml.Name{Local: "w"}), &NSNormalizer{})).Should(Equal(res))
+	Ω(process(doc, &NSNormalizer{}, UnwrapElement(xml.Name{Local: "w"}))).Should(Equal(res))
+}
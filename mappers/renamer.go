@@ -0,0 +1,78 @@
+package mappers
+
+import "encoding/xml"
+
+// Renamer renames elements and moves them between namespaces.
+// Names maps the resolved names of elements to the new names; a key with
+// an empty Space matches elements of any namespace with the local name.
+// Func, if set, renames the elements missing in Names; it gets the start
+// tag with the name resolved to the namespace URI, and returns the new name
+// and whether the element should be renamed.
+// End tags always get the same name as the matching start tags.
+//
+// If the namespace of a new name is not declared in the scope of an element,
+// the declaration is added to the element. The prefix is taken from
+// Prefixes, a map of namespace URIs to the preferred prefixes, or generated.
+// The mapper can run either before or after NSNormalizer.
+type Renamer struct {
+	Names    map[xml.Name]xml.Name
+	Prefixes map[string]string
+	Func     func(xml.StartElement) (xml.Name, bool)
+
+	ns    nsTracker
+	stack []*xml.Name
+}
+
+func (m *Renamer) Map(t xml.Token) (xml.Token, error) {
+	switch token := t.(type) {
+	case xml.StartElement:
+		m.ns.push(token)
+
+		name, ok := m.lookup(token)
+		if !ok {
+			m.stack = append(m.stack, nil)
+			return t, nil
+		}
+
+		token = token.Copy()
+		normalized := isNormalized(token)
+		if name.Space != "" {
+			if _, bound := m.ns.uriPrefix(name.Space, false); !bound {
+				token.Attr = append(token.Attr, m.ns.declare(name.Space, m.Prefixes, normalized))
+			}
+		}
+		token.Name = m.ns.qualify(name, false, normalized)
+		m.stack = append(m.stack, &token.Name)
+		return token, nil
+
+	case xml.EndElement:
+		m.ns.pop()
+		if len(m.stack) == 0 {
+			return t, nil
+		}
+
+		name := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		if name != nil {
+			token.Name = *name
+		}
+		return token, nil
+
+	default:
+		return t, nil
+	}
+}
+
+func (m *Renamer) lookup(t xml.StartElement) (xml.Name, bool) {
+	t.Name = m.ns.resolve(t.Name, false)
+	if n, ok := m.Names[t.Name]; ok {
+		return n, true
+	}
+	if n, ok := m.Names[xml.Name{Local: t.Name.Local}]; ok {
+		return n, true
+	}
+	if m.Func != nil {
+		return m.Func(t)
+	}
+	return xml.Name{}, false
+}
@@ -0,0 +1,67 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRenamerBeforeAndAfterNormalizer(t *testing.T) {
+	RegisterTestingT(t)
+
+	const doc = `<r xmlns="urn:r" xmlns:p="urn:p"><p:a><b></b></p:a><a></a></r>`
+
+	for _, tc := range []struct {
+		names    map[xml.Name]xml.Name
+		prefixes map[string]string
+		res      string
+	}{
+		{
+			map[xml.Name]xml.Name{elemA: {Space: "urn:p", Local: "c"}},
+			nil,
+			`<r xmlns="urn:r" xmlns:p="urn:p"><p:c><b></b></p:c><a></a></r>`,
+		},
+		{
+			map[xml.Name]xml.Name{{Local: "a"}: {Space: "urn:r", Local: "c"}},
+			nil,
+			`<r xmlns="urn:r" xmlns:p="urn:p"><c><b></b></c><c></c></r>`,
+		},
+		{
+			map[xml.Name]xml.Name{{Space: "urn:r", Local: "b"}: {Space: "urn:p", Local: "b"}},
+			nil,
+			`<r xmlns="urn:r" xmlns:p="urn:p"><p:a><p:b></p:b></p:a><a></a></r>`,
+		},
+		{
+			map[xml.Name]xml.Name{elemA: {Space: "urn:n", Local: "a"}},
+			nil,
+			`<r xmlns="urn:r" xmlns:p="urn:p"><ns1:a xmlns:ns1="urn:n"><b></b></ns1:a><a></a></r>`,
+		},
+		{
+			map[xml.Name]xml.Name{elemA: {Space: "urn:n", Local: "a"}},
+			map[string]string{"urn:n": "n"},
+			`<r xmlns="urn:r" xmlns:p="urn:p"><n:a xmlns:n="urn:n"><b></b></n:a><a></a></r>`,
+		},
+		{
+			map[xml.Name]xml.Name{elemA: {Space: "urn:n", Local: "a"}},
+			map[string]string{"urn:n": "p"},
+			`<r xmlns="urn:r" xmlns:p="urn:p"><ns1:a xmlns:ns1="urn:n"><b></b></ns1:a><a></a></r>`,
+		},
+	} {
+		before := &Renamer{Names: tc.names, Prefixes: tc.prefixes}
+		after := &Renamer{Names: tc.names, Prefixes: tc.prefixes}
+		Ω(process(doc, before, &NSNormalizer{})).Should(Equal(tc.res))
+		Ω(process(doc, &NSNormalizer{}, after)).Should(Equal(tc.res))
+	}
+}
+
+func TestRenamerNested(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := &Renamer{Names: map[xml.Name]xml.Name{
+		{Local: "a"}: {Local: "b"},
+		{Local: "b"}: {Local: "a"},
+	}}
+	Ω(process(`<a><b><a></a></b></a>`, m, &NSNormalizer{})).
+		Should(Equal(`<b><a><b></b></a></b>`))
+}
@@ -0,0 +1,81 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"regexp"
+)
+
+// Text is a mapper rewriting the character data of elements.
+// Consecutive CharData tokens, e.g. text split by CDATA sections or by
+// comments removed by a preceding mapper, are merged and rewritten as
+// a whole, so the mapper emits the text when the following token arrives.
+// Only the text directly contained by the elements matching Element,
+// similarly to Attr, is rewritten; an empty Element.Local matches any
+// element. Match, if set, filters the elements further; it gets the start
+// tags with the names resolved to the namespace URIs.
+// If Func returns an empty string, the text is removed.
+// Use ReplaceText and TransformText to create the mapper.
+type Text struct {
+	Element xml.Name
+	Match   func(xml.StartElement) bool
+	Func    func(string) (string, error)
+
+	ns    nsTracker
+	stack []bool
+	buf   []byte
+	// buffered reports whether the text is being collected.
+	buffered bool
+}
+
+// ReplaceText replaces the matches of a regular expression in the text,
+// see regexp.Regexp.ReplaceAllString.
+func ReplaceText(element xml.Name, re *regexp.Regexp, repl string) *Text {
+	return &Text{Element: element, Func: func(s string) (string, error) {
+		return re.ReplaceAllString(s, repl), nil
+	}}
+}
+
+// TransformText replaces the text with the result of a function.
+func TransformText(element xml.Name, fn func(string) (string, error)) *Text {
+	return &Text{Element: element, Func: fn}
+}
+
+func (m *Text) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapTokens(t))
+}
+
+func (m *Text) MapTokens(t xml.Token) ([]xml.Token, error) {
+	if data, ok := t.(xml.CharData); ok {
+		if len(m.stack) == 0 || !m.stack[len(m.stack)-1] {
+			return []xml.Token{t}, nil
+		}
+		m.buf = append(m.buf, data...)
+		m.buffered = true
+		return nil, nil
+	}
+
+	var res []xml.Token
+	if m.buffered {
+		text, err := m.Func(string(m.buf))
+		m.buf = m.buf[:0]
+		m.buffered = false
+		if err != nil {
+			return nil, err
+		}
+		if text != "" {
+			res = append(res, xml.CharData(text))
+		}
+	}
+
+	switch token := t.(type) {
+	case xml.StartElement:
+		m.ns.push(token)
+		m.stack = append(m.stack, m.ns.matchElement(m.Element, m.Match, token))
+	case xml.EndElement:
+		m.ns.pop()
+		if len(m.stack) > 0 {
+			m.stack = m.stack[:len(m.stack)-1]
+		}
+	}
+	return append(res, t), nil
+}
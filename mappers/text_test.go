@@ -0,0 +1,47 @@
+package mappers
+
+import (
+	"encoding/xml"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestText(t *testing.T) {
+	RegisterTestingT(t)
+
+	const doc = `<r xmlns:p="urn:p"><p:a>1##comma##2<b>3##comma##4</b>5##com<![CDATA[ma##]]>6</p:a>7##comma##8</r>`
+	comma := regexp.MustCompile("##comma##")
+
+	Ω(process(doc, ReplaceText(elemA, comma, ","), &NSNormalizer{})).
+		Should(Equal(`<r xmlns:p="urn:p"><p:a>1,2<b>3##comma##4</b>5,6</p:a>7##comma##8</r>`))
+	Ω(process(doc, &NSNormalizer{}, ReplaceText(xml.Name{}, comma, ","))).
+		Should(Equal(`<r xmlns:p="urn:p"><p:a>1,2<b>3,4</b>5,6</p:a>7,8</r>`))
+}
+
+func TestTextMergesSplitData(t *testing.T) {
+	RegisterTestingT(t)
+
+	var texts []string
+	m := TransformText(xml.Name{Local: "a"}, func(s string) (string, error) {
+		texts = append(texts, s)
+		return "", nil
+	})
+	Ω(process(`<a>x<!--c-->y<![CDATA[z]]></a>`, &Pruner{}, m)).Should(Equal(`<a></a>`))
+	Ω(texts).Should(Equal([]string{"xyz"}))
+}
+
+func TestTextError(t *testing.T) {
+	RegisterTestingT(t)
+
+	m := TransformText(xml.Name{}, func(s string) (string, error) {
+		return "", errors.New(strings.ToUpper(s))
+	})
+	Ω(m.MapTokens(xml.StartElement{Name: xml.Name{Local: "a"}})).Should(HaveLen(1))
+	Ω(m.MapTokens(xml.CharData("x"))).Should(BeEmpty())
+	_, err := m.MapTokens(xml.EndElement{Name: xml.Name{Local: "a"}})
+	Ω(err).Should(MatchError("X"))
+}
@@ -0,0 +1,64 @@
+package mappers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// ErrMultipleTokens is returned by the Map method of a multi-token mapper
+// when it is called directly and the mapping produces more than one token.
+var ErrMultipleTokens = errors.New("xmlproc: mapper produced multiple tokens")
+
+// Single converts a result of a multi-token mapping into a single token.
+// It's intended for implementing Map method of multi-token mappers.
+func Single(ts []xml.Token, err error) (xml.Token, error) {
+	switch {
+	case err != nil:
+		return nil, err
+	case len(ts) == 0:
+		return nil, nil
+	case len(ts) == 1:
+		return ts[0], nil
+	default:
+		return nil, ErrMultipleTokens
+	}
+}
+
+// ParseTokens parses an XML fragment, e.g. a few elements and text,
+// into tokens. Similarly to the tokens read from a document, element and
+// attribute names hold namespace URIs.
+func ParseTokens(s string) ([]xml.Token, error) {
+	d := xml.NewDecoder(bytes.NewBufferString(s))
+	var ts []xml.Token
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return ts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, xml.CopyToken(t))
+	}
+}
+
+// MustParseTokens is like ParseTokens but panics if the fragment cannot
+// be parsed.
+func MustParseTokens(s string) []xml.Token {
+	ts, err := ParseTokens(s)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
+
+// MarshalTokens encodes a value using xml.Marshal and returns the tokens.
+func MarshalTokens(v interface{}) ([]xml.Token, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTokens(string(data))
+}
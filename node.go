@@ -0,0 +1,124 @@
+package xmlproc
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+// Node is an in-memory representation of a part of a document.
+// An element node holds an xml.StartElement token and the child nodes;
+// any other node holds a single token, e.g. xml.CharData or xml.Comment.
+type Node struct {
+	Token    xml.Token
+	Children []*Node
+}
+
+// NewElement creates an element node.
+func NewElement(name xml.Name, attr ...xml.Attr) *Node {
+	return &Node{Token: xml.StartElement{Name: name, Attr: attr}}
+}
+
+// NewText creates a character data node.
+func NewText(text string) *Node {
+	return &Node{Token: xml.CharData(text)}
+}
+
+// BuildNode builds a tree from the tokens of a single element:
+// the start tag, the content and the matching end tag.
+func BuildNode(tokens []xml.Token) (*Node, error) {
+	var stack []*Node
+	var root *Node
+
+	for _, t := range tokens {
+		switch token := t.(type) {
+		case xml.StartElement:
+			n := &Node{Token: token.Copy()}
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				top.Children = append(top.Children, n)
+			} else if root != nil {
+				return nil, errors.New("xmlproc: multiple root elements")
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, errors.New("xmlproc: unexpected end element")
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			if len(stack) == 0 {
+				return nil, errors.New("xmlproc: token outside of element")
+			}
+			top := stack[len(stack)-1]
+			top.Children = append(top.Children, &Node{Token: xml.CopyToken(t)})
+		}
+	}
+
+	if root == nil || len(stack) > 0 {
+		return nil, errors.New("xmlproc: incomplete element")
+	}
+	return root, nil
+}
+
+// IsElement reports whether the node is an element node.
+func (n *Node) IsElement() bool {
+	_, ok := n.Token.(xml.StartElement)
+	return ok
+}
+
+// Start returns the start tag of an element node.
+func (n *Node) Start() xml.StartElement {
+	start, _ := n.Token.(xml.StartElement)
+	return start
+}
+
+// Elements returns the child element nodes.
+func (n *Node) Elements() []*Node {
+	var res []*Node
+	for _, c := range n.Children {
+		if c.IsElement() {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Text returns the concatenated character data of the node and
+// all its descendants.
+func (n *Node) Text() string {
+	var b strings.Builder
+	n.text(&b)
+	return b.String()
+}
+
+func (n *Node) text(b *strings.Builder) {
+	if data, ok := n.Token.(xml.CharData); ok {
+		b.Write(data)
+	}
+	for _, c := range n.Children {
+		c.text(b)
+	}
+}
+
+// Tokens returns the token sequence representing the node.
+func (n *Node) Tokens() []xml.Token {
+	return n.tokens(nil)
+}
+
+func (n *Node) tokens(out []xml.Token) []xml.Token {
+	start, ok := n.Token.(xml.StartElement)
+	if !ok {
+		return append(out, n.Token)
+	}
+
+	out = append(out, start)
+	for _, c := range n.Children {
+		out = c.tokens(out)
+	}
+	return append(out, start.End())
+}
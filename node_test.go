@@ -0,0 +1,61 @@
+package xmlproc
+
+import (
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestBuildNode(t *testing.T) {
+	RegisterTestingT(t)
+
+	a := xml.StartElement{Name: xml.Name{Local: "a"}, Attr: []xml.Attr{}}
+	b := xml.StartElement{Name: xml.Name{Local: "b"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "x"}, Value: "1"},
+	}}
+	tokens := []xml.Token{
+		a,
+		xml.CharData("one "),
+		b,
+		xml.CharData("two"),
+		b.End(),
+		xml.Comment("c"),
+		a.End(),
+	}
+
+	n, err := BuildNode(tokens)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(n.IsElement()).Should(BeTrue())
+	Ω(n.Start().Name.Local).Should(Equal("a"))
+	Ω(n.Children).Should(HaveLen(3))
+	Ω(n.Elements()).Should(HaveLen(1))
+	Ω(n.Elements()[0].Start()).Should(Equal(b))
+	Ω(n.Text()).Should(Equal("one two"))
+	Ω(n.Tokens()).Should(Equal(tokens))
+
+	_, err = BuildNode(tokens[:3])
+	Ω(err).Should(HaveOccurred())
+	_, err = BuildNode(tokens[1:])
+	Ω(err).Should(HaveOccurred())
+	_, err = BuildNode(append(tokens, a, a.End()))
+	Ω(err).Should(HaveOccurred())
+	_, err = BuildNode(nil)
+	Ω(err).Should(HaveOccurred())
+}
+
+func TestNewNodes(t *testing.T) {
+	RegisterTestingT(t)
+
+	n := NewElement(xml.Name{Local: "a"}, xml.Attr{Name: xml.Name{Local: "x"}, Value: "1"})
+	n.Children = append(n.Children, NewText("text"))
+	Ω(n.Tokens()).Should(Equal([]xml.Token{
+		xml.StartElement{
+			Name: xml.Name{Local: "a"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "x"}, Value: "1"}},
+		},
+		xml.CharData("text"),
+		xml.EndElement{Name: xml.Name{Local: "a"}},
+	}))
+	Ω(NewText("x").IsElement()).Should(BeFalse())
+}
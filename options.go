@@ -0,0 +1,111 @@
+package xmlproc
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Option configures a Processor, see NewProcessor.
+type Option func(*Processor)
+
+// NewProcessor creates a Processor configured with the given options.
+func NewProcessor(opts ...Option) *Processor {
+	p := &Processor{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithMappers appends mappers to the processor's map list.
+func WithMappers(mm ...Mapper) Option {
+	return func(p *Processor) {
+		p.Mappers = append(p.Mappers, mm...)
+	}
+}
+
+// WithFormat sets the layout of the output documents.
+func WithFormat(f Format) Option {
+	return func(p *Processor) {
+		p.Format = f
+	}
+}
+
+// WithLossless enables or disables the lossless mode.
+func WithLossless(lossless bool) Option {
+	return func(p *Processor) {
+		p.Lossless = lossless
+	}
+}
+
+// WithEncoding sets the character encoding of the output documents.
+func WithEncoding(encoding string) Option {
+	return func(p *Processor) {
+		p.Encoding = encoding
+	}
+}
+
+// WithLimits sets the limits of the resources a document may consume.
+func WithLimits(l Limits) Option {
+	return func(p *Processor) {
+		p.Limits = l
+	}
+}
+
+// WithDecoder adds a function configuring the decoders created by
+// ProcessStreams.
+func WithDecoder(fn func(*xml.Decoder)) Option {
+	return func(p *Processor) {
+		p.DecoderOptions = append(p.DecoderOptions, fn)
+	}
+}
+
+// WithEncoder adds a function configuring the encoders created by
+// ProcessStreams. The encoder is configured after the output format is
+// applied, so it can override the indentation.
+// Encoder options are ignored when the output format or the lossless mode
+// requires a custom writer.
+func WithEncoder(fn func(*xml.Encoder)) Option {
+	return func(p *Processor) {
+		p.EncoderOptions = append(p.EncoderOptions, fn)
+	}
+}
+
+// Strict sets the Strict mode of the decoder, see xml.Decoder.
+func Strict(strict bool) Option {
+	return WithDecoder(func(d *xml.Decoder) {
+		d.Strict = strict
+	})
+}
+
+// AutoClose sets the elements the decoder closes automatically in
+// non-strict mode, see xml.Decoder.
+func AutoClose(names ...string) Option {
+	return WithDecoder(func(d *xml.Decoder) {
+		d.AutoClose = names
+	})
+}
+
+// Entities sets the entities the decoder recognizes in addition to the
+// standard XML ones, see xml.Decoder.
+func Entities(entities map[string]string) Option {
+	return WithDecoder(func(d *xml.Decoder) {
+		d.Entity = entities
+	})
+}
+
+// CharsetReader sets the function the decoder uses to convert non-UTF-8
+// input, see xml.Decoder.
+func CharsetReader(fn func(charset string, input io.Reader) (io.Reader, error)) Option {
+	return WithDecoder(func(d *xml.Decoder) {
+		d.CharsetReader = fn
+	})
+}
+
+// HTMLLenient configures the decoder to accept HTML-ish input:
+// non-strict parsing, HTML auto-closed elements and HTML entities.
+func HTMLLenient(p *Processor) {
+	Strict(false)(p)
+	AutoClose(xml.HTMLAutoClose...)(p)
+	Entities(xml.HTMLEntity)(p)
+}
@@ -0,0 +1,74 @@
+package xmlproc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	. "github.com/onsi/gomega"
+)
+
+func TestNewProcessor(t *testing.T) {
+	RegisterTestingT(t)
+
+	p := NewProcessor(
+		WithMappers(&mappers.Pruner{}),
+		WithMappers(&mappers.NSNormalizer{}),
+		WithFormat(Format{Style: FormatMinify}),
+		WithLossless(true),
+		Strict(false),
+	)
+	Ω(p.Mappers).Should(HaveLen(2))
+	Ω(p.Format.Style).Should(Equal(FormatMinify))
+	Ω(p.Lossless).Should(BeTrue())
+	Ω(p.DecoderOptions).Should(HaveLen(1))
+
+	p = NewDefaultProcessor(WithMappers(&mappers.Logger{Writer: ioutil.Discard}))
+	Ω(p.Mappers).Should(HaveLen(3))
+}
+
+func TestHTMLLenient(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<div>a&nbsp;b<br><p>text</div>`
+
+	err := NewProcessor().ProcessStreams(ioutil.Discard, bytes.NewBufferString(src))
+	Ω(err).Should(HaveOccurred())
+
+	dst := &bytes.Buffer{}
+	err = NewProcessor(HTMLLenient, WithFormat(Format{Style: FormatPreserve})).
+		ProcessStreams(dst, bytes.NewBufferString(src))
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(dst.String()).Should(Equal("<div>a\u00a0b<br></br><p>text</p></div>"))
+}
+
+func TestCharsetReaderOption(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<?xml version="1.0" encoding="x-upper"?><a>text</a>`
+	upper := func(charset string, input io.Reader) (io.Reader, error) {
+		b, err := ioutil.ReadAll(input)
+		return strings.NewReader(strings.ToUpper(string(b))), err
+	}
+
+	dst := &bytes.Buffer{}
+	err := NewProcessor(
+		CharsetReader(upper),
+		WithEncoder(func(e *xml.Encoder) { e.Indent("", "") }),
+	).ProcessStreams(dst, bytes.NewBufferString(src))
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(dst.String()).Should(Equal(`<?xml version="1.0" encoding="x-upper"?><A>TEXT</A>`))
+
+	// The conversion changes the length of the input.
+	shorten := func(charset string, input io.Reader) (io.Reader, error) {
+		b, err := ioutil.ReadAll(input)
+		return strings.NewReader(strings.Replace(string(b), "text", "t", -1)), err
+	}
+	err = NewProcessor(CharsetReader(shorten), WithLossless(true)).
+		ProcessStreams(&bytes.Buffer{}, bytes.NewBufferString(src))
+	Ω(err).Should(MatchError(ContainSubstring("unsupported encoding in lossless mode: x-upper")))
+}
@@ -2,7 +2,9 @@
 package xmlproc
 
 import (
+	"context"
 	"encoding/xml"
+	"fmt"
 	"io"
 
 	"github.com/PlanitarInc/go-xmlproc/mappers"
@@ -11,17 +13,43 @@ import (
 // Processor type encapsulates the main logic of processing an XML file.
 type Processor struct {
 	Mappers []Mapper
+	// Format defines the layout of the documents written by ProcessStreams.
+	Format Format
+	// Lossless makes ProcessStreams copy the original text of every token
+	// not modified by the mappers, instead of re-encoding it.
+	// Format is ignored in the lossless mode. Documents in encodings not
+	// supported by the processor are rejected in the lossless mode, even if
+	// the decoder has a CharsetReader.
+	Lossless bool
+	// DecoderOptions and EncoderOptions configure the decoders and encoders
+	// created by ProcessStreams.
+	DecoderOptions []func(*xml.Decoder)
+	EncoderOptions []func(*xml.Encoder)
+	// Encoding is the character encoding of the documents written by
+	// ProcessStreams, UTF-8 if empty. Supported encodings are UTF-8,
+	// UTF-16 (UTF-16LE, UTF-16BE), US-ASCII, ISO-8859-1 and windows-1252.
+	// The same encodings are detected and converted in the input documents.
+	// Characters missing in the encoding are written as character references
+	// in the text and attribute values, and reported as errors elsewhere.
+	Encoding string
+	// Limits restricts the resources a processed document may consume.
+	Limits Limits
 }
 
 // Create a Processor with predefined set of mappers:
 // mapper.Pruner and mapper.NSNormalizer.
-func NewDefaultProcessor() *Processor {
-	return &Processor{
+// The processor is further configured by the given options.
+func NewDefaultProcessor(opts ...Option) *Processor {
+	p := &Processor{
 		Mappers: []Mapper{
 			&mappers.Pruner{},
 			&mappers.NSNormalizer{},
 		},
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // AddMapper appends a mapper to processor's map list.
@@ -46,42 +74,196 @@ func (p *Processor) RemMapper(m Mapper) {
 // processes it by applying the mappers, and
 // writes the resulting XML file to dst.
 func (p Processor) ProcessStreams(dst io.Writer, src io.Reader) error {
-	e := xml.NewEncoder(dst)
-	e.Indent("", "  ")
-	defer e.Flush()
-	d := xml.NewDecoder(src)
-	return p.Process(e, d)
+	return p.ProcessStreamsContext(context.Background(), dst, src)
+}
+
+// ProcessStreamsContext is like ProcessStreams but aborts the processing
+// once the context is done.
+func (p Processor) ProcessStreamsContext(ctx context.Context, dst io.Writer, src io.Reader) error {
+	var w tokenWriter
+	var d *xml.Decoder
+
+	out := charsetUTF8
+	if p.Encoding != "" {
+		if out = lookupCharset(p.Encoding); out == nil {
+			return fmt.Errorf("xmlproc: unsupported encoding: %s", p.Encoding)
+		}
+		dst = newCharsetWriter(dst, out)
+	}
+
+	if p.Limits.MaxBytes > 0 {
+		src = &limitReader{r: src, max: p.Limits.MaxBytes}
+	}
+
+	src, in := detectCharset(src)
+	if in != nil || p.Encoding != "" {
+		p.addMapper(&declarationMapper{encoding: out.name})
+	}
+	if out != charsetUTF8 {
+		p.addMapper(charsetChecker{cs: out})
+	}
+
+	if p.Lossless {
+		rec := newRecorder(src)
+		w = newLosslessWriter(dst, rec)
+		d = xml.NewDecoder(rec)
+	} else {
+		if m := p.Format.mapper(); m != nil {
+			p.addMapper(m)
+		}
+		w = p.Format.writer(dst)
+		d = xml.NewDecoder(src)
+	}
+
+	for _, fn := range p.DecoderOptions {
+		fn(d)
+	}
+	if e, ok := w.(*xml.Encoder); ok {
+		for _, fn := range p.EncoderOptions {
+			fn(e)
+		}
+	}
+
+	// The input is already converted to UTF-8 if its charset is supported.
+	fallback := d.CharsetReader
+	d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if in != nil {
+			return input, nil
+		}
+		if p.Lossless {
+			// The raw text is recorded before the conversion, so the offsets
+			// of the decoder wouldn't match it.
+			return nil, fmt.Errorf("xmlproc: unsupported encoding in lossless mode: %s", label)
+		}
+		if fallback != nil {
+			return fallback(label, input)
+		}
+		return nil, fmt.Errorf("xmlproc: unsupported encoding: %s", label)
+	}
+
+	if err := p.process(ctx, w, d); err != nil {
+		w.Flush()
+		return err
+	}
+	return w.Flush()
+}
+
+// addMapper appends a mapper to a copy of the map list,
+// leaving the original list intact.
+func (p *Processor) addMapper(m Mapper) {
+	p.Mappers = append(p.Mappers[:len(p.Mappers):len(p.Mappers)], m)
 }
 
 // Process reads XML tokens using the provided decoder,
 // processes them by applying the mappers, and
 // writes the resulting XML token using the provided encoder.
 func (p Processor) Process(e *xml.Encoder, d *xml.Decoder) error {
+	return p.ProcessContext(context.Background(), e, d)
+}
+
+// ProcessContext is like Process but checks the context between tokens,
+// and aborts the processing with the context error once it's done.
+// The context is available to context-aware mappers, see Context.Context.
+func (p Processor) ProcessContext(ctx context.Context, e *xml.Encoder, d *xml.Decoder) error {
+	return p.process(ctx, e, d)
+}
+
+func (p Processor) process(ctx context.Context, w tokenWriter, d *xml.Decoder) error {
+	c := mappers.NewContext(ctx)
+	lim := &limiter{Limits: p.Limits}
 	for {
+		if err := ctx.Err(); err != nil {
+			return p.error(c, err, nil)
+		}
+
+		c.Offset = d.InputOffset()
+		c.Line, c.Column = d.InputPos()
+
 		t, err := d.Token()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return err
+			return p.error(c, err, nil)
 		}
 
-		for _, m := range p.Mappers {
-			if token, err := m.Map(t); err != nil {
-				return err
-			} else if t == nil {
-				break
-			} else {
-				t = token
-			}
+		if start, ok := t.(xml.StartElement); ok {
+			c.Enter(start)
 		}
 
-		if t == nil {
+		if err := lim.check(c, t, d.InputOffset()-c.Offset); err != nil {
+			return p.error(c, err, t)
+		}
+
+		lw, lossless := w.(*losslessWriter)
+		var orig xml.Token
+		if lossless {
+			orig = xml.CopyToken(t)
+		}
+
+		tokens, err := p.apply(c, 0, t, nil)
+		if err != nil {
+			return p.error(c, err, t)
+		}
+
+		if _, ok := t.(xml.EndElement); ok {
+			c.Leave()
+		}
+
+		if lossless {
+			if err := lw.writeTokens(orig, c.Offset, d.InputOffset(), tokens); err != nil {
+				return p.error(c, err, orig)
+			}
 			continue
 		}
 
-		if err := e.EncodeToken(t); err != nil {
-			return err
+		for _, t := range tokens {
+			if err := w.EncodeToken(t); err != nil {
+				return p.error(c, err, t)
+			}
 		}
 	}
 	return nil
 }
+
+// error wraps an error with the position of the current token.
+// Errors returned by mappers are already wrapped by apply.
+func (p Processor) error(c *Context, err error, t xml.Token) error {
+	pe, ok := err.(*ProcessError)
+	if !ok {
+		pe = &ProcessError{Token: t, MapperIndex: -1, Err: err}
+	}
+	pe.Offset = c.Offset
+	pe.Line, pe.Column = c.Line, c.Column
+	pe.Path = c.Path.String()
+	return pe
+}
+
+// apply passes a token through the mappers starting at index i,
+// and appends the tokens produced by the last mapper to out.
+// Every token emitted by a mapper is passed to the following mappers
+// in the order of emission.
+func (p Processor) apply(c *Context, i int, t xml.Token, out []xml.Token) ([]xml.Token, error) {
+	if i == len(p.Mappers) {
+		return append(out, t), nil
+	}
+
+	tokens, err := Apply(p.Mappers[i], c, t)
+	if err != nil {
+		return nil, &ProcessError{
+			Token:       t,
+			MapperIndex: i,
+			Mapper:      p.Mappers[i],
+			Err:         err,
+		}
+	}
+
+	for _, t := range tokens {
+		if t == nil {
+			continue
+		}
+		if out, err = p.apply(c, i+1, t, out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
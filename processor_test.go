@@ -0,0 +1,122 @@
+package xmlproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	. "github.com/onsi/gomega"
+)
+
+var errFailing = errors.New("failing")
+
+// failing is a mapper failing on elements with the given local name.
+type failing string
+
+func (m failing) Map(t xml.Token) (xml.Token, error) {
+	if start, ok := t.(xml.StartElement); ok && start.Name.Local == string(m) {
+		return nil, errFailing
+	}
+	return t, nil
+}
+
+func TestProcessMapperError(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := "<a>\n  <b>\n    <c/>\n  </b>\n</a>"
+	p := Processor{
+		Mappers: []Mapper{
+			&mappers.Pruner{},
+			failing("c"),
+		},
+	}
+	err := p.ProcessStreams(ioutil.Discard, bytes.NewBufferString(src))
+	Ω(err).Should(HaveOccurred())
+	Ω(errors.Is(err, errFailing)).Should(BeTrue())
+
+	var pe *ProcessError
+	Ω(errors.As(err, &pe)).Should(BeTrue())
+	Ω(pe.Offset).Should(Equal(int64(14)))
+	Ω(pe.Line).Should(Equal(3))
+	Ω(pe.Column).Should(Equal(5))
+	Ω(pe.Path).Should(Equal("/a/b/c"))
+	Ω(pe.MapperIndex).Should(Equal(1))
+	Ω(pe.Mapper).Should(Equal(failing("c")))
+	Ω(pe.Token).Should(BeAssignableToTypeOf(xml.StartElement{}))
+	Ω(pe.Error()).Should(Equal(
+		"xmlproc: line 3, column 5 /a/b/c: mapper #1 (xmlproc.failing): failing"))
+}
+
+func TestProcessSyntaxError(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := "<a>\n  <b></c>\n</a>"
+	err := Processor{}.ProcessStreams(ioutil.Discard, bytes.NewBufferString(src))
+	Ω(err).Should(HaveOccurred())
+
+	var pe *ProcessError
+	Ω(errors.As(err, &pe)).Should(BeTrue())
+	Ω(pe.MapperIndex).Should(Equal(-1))
+	Ω(pe.Line).Should(Equal(2))
+
+	var se *xml.SyntaxError
+	Ω(errors.As(err, &se)).Should(BeTrue())
+}
+
+// canceler is a context-aware mapper canceling the processing on elements
+// with the given local name.
+type canceler struct {
+	name   string
+	cancel context.CancelFunc
+	seen   []string
+}
+
+func (m *canceler) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapContext(&Context{}, t))
+}
+
+func (m *canceler) MapContext(c *Context, t xml.Token) ([]xml.Token, error) {
+	if start, ok := t.(xml.StartElement); ok {
+		m.seen = append(m.seen, start.Name.Local)
+		if start.Name.Local == m.name {
+			m.cancel()
+			Ω(c.Context().Err()).Should(Equal(context.Canceled))
+		}
+	}
+	return []xml.Token{t}, nil
+}
+
+func TestProcessContextCancel(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := "<a><b/><c/><d/></a>"
+	m := &canceler{name: "b", cancel: cancel}
+	p := Processor{Mappers: []Mapper{m}}
+
+	err := p.ProcessStreamsContext(ctx, ioutil.Discard, bytes.NewBufferString(src))
+	Ω(errors.Is(err, context.Canceled)).Should(BeTrue())
+	Ω(m.seen).Should(Equal([]string{"a", "b"}))
+
+	var pe *ProcessError
+	Ω(errors.As(err, &pe)).Should(BeTrue())
+	Ω(pe.Path).Should(Equal("/a/b"))
+	Ω(pe.MapperIndex).Should(Equal(-1))
+}
+
+func TestProcessContextDone(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Processor{}.ProcessStreamsContext(ctx, ioutil.Discard, bytes.NewBufferString("<a/>"))
+	Ω(errors.Is(err, context.Canceled)).Should(BeTrue())
+	Ω((&Context{}).Context()).Should(Equal(context.Background()))
+}
@@ -0,0 +1,162 @@
+package rules
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PlanitarInc/go-xmlproc"
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	"github.com/PlanitarInc/go-xmlproc/selector"
+)
+
+// qname is a qualified name of a rule. The prefix is resolved using
+// the namespaces of the rule file when the rule is compiled, or using
+// the declarations of a processed document otherwise.
+type qname struct {
+	name xml.Name
+	// prefix is the prefix left to be resolved against the document.
+	prefix string
+}
+
+func parseQName(s string, namespaces map[string]string) qname {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return qname{name: xml.Name{Local: s}}
+	}
+	prefix, local := s[:i], s[i+1:]
+	if prefix == "xml" {
+		return qname{name: xml.Name{Space: mappers.XMLNamespace, Local: local}}
+	}
+	if uri, ok := namespaces[prefix]; ok {
+		return qname{name: xml.Name{Space: uri, Local: local}}
+	}
+	return qname{name: xml.Name{Local: local}, prefix: prefix}
+}
+
+// resolve returns the name with the namespace URI in the Space field.
+func (n qname) resolve(path mappers.Path) (xml.Name, error) {
+	if n.prefix == "" {
+		return n.name, nil
+	}
+	uri, ok := path.LookupPrefix(n.prefix)
+	if !ok {
+		return xml.Name{}, fmt.Errorf("undeclared prefix %q", n.prefix)
+	}
+	return xml.Name{Space: uri, Local: n.name.Local}, nil
+}
+
+// selection applies a mapper to every token, so the mapper keeps track of
+// the namespace declarations of the document, and records whether
+// the current token is selected, for the element filters of the mapper.
+type selection struct {
+	selector *selector.Selector
+	mapper   xmlproc.Mapper
+	selected bool
+}
+
+func (m *selection) Map(t xml.Token) (xml.Token, error) {
+	return xmlproc.Single(m.MapContext(&xmlproc.Context{}, t))
+}
+
+func (m *selection) MapContext(c *xmlproc.Context, t xml.Token) ([]xml.Token, error) {
+	m.selected = m.selector.MatchToken(c, t)
+	return xmlproc.Apply(m.mapper, c, t)
+}
+
+func (m *selection) match(xml.StartElement) bool {
+	return m.selected
+}
+
+// deleter drops the selected elements together with their content.
+func deleter(s *selector.Selector) *selection {
+	m := &selection{selector: s}
+	m.mapper = &mappers.Remover{Func: m.match}
+	return m
+}
+
+// renamer renames the selected elements, declaring the namespace of
+// the new name if it's not declared in scope.
+type renamer struct {
+	selector *selector.Selector
+	name     qname
+	mapper   *mappers.Renamer
+	// resolved is the new name of the current element, if it's selected.
+	resolved *xml.Name
+}
+
+func newRenamer(s *selector.Selector, name qname, prefixes map[string]string) *renamer {
+	m := &renamer{selector: s, name: name}
+	m.mapper = &mappers.Renamer{
+		Prefixes: prefixes,
+		Func: func(xml.StartElement) (xml.Name, bool) {
+			if m.resolved == nil {
+				return xml.Name{}, false
+			}
+			return *m.resolved, true
+		},
+	}
+	return m
+}
+
+func (m *renamer) Map(t xml.Token) (xml.Token, error) {
+	return xmlproc.Single(m.MapContext(&xmlproc.Context{}, t))
+}
+
+func (m *renamer) MapContext(c *xmlproc.Context, t xml.Token) ([]xml.Token, error) {
+	m.resolved = nil
+	if _, ok := t.(xml.StartElement); ok && m.selector.MatchToken(c, t) {
+		name, err := m.name.resolve(c.Path)
+		if err != nil {
+			return nil, fmt.Errorf("rename: %v", err)
+		}
+		m.resolved = &name
+	}
+	return xmlproc.Apply(m.mapper, c, t)
+}
+
+// attrSetter sets an attribute of the selected start tags, adding it if
+// missing, and declaring its namespace if needed. Unprefixed names match
+// only the attributes with no namespace.
+type attrSetter struct {
+	selection
+	name qname
+	attr *mappers.Attr
+}
+
+func newAttrSetter(s *selector.Selector, name qname, value string, prefixes map[string]string) *attrSetter {
+	m := &attrSetter{selection: selection{selector: s}, name: name}
+	m.attr = mappers.SetAttr(xml.Name{}, name.name, value)
+	m.attr.Match = m.match
+	m.attr.ExactName = true
+	m.attr.Prefixes = prefixes
+	m.mapper = m.attr
+	return m
+}
+
+func (m *attrSetter) Map(t xml.Token) (xml.Token, error) {
+	return xmlproc.Single(m.MapContext(&xmlproc.Context{}, t))
+}
+
+func (m *attrSetter) MapContext(c *xmlproc.Context, t xml.Token) ([]xml.Token, error) {
+	m.selected = m.selector.MatchToken(c, t)
+	if _, ok := t.(xml.StartElement); ok && m.selected {
+		name, err := m.name.resolve(c.Path)
+		if err != nil {
+			return nil, fmt.Errorf("set-attr: %v", err)
+		}
+		m.attr.Name = name
+	}
+	return xmlproc.Apply(m.mapper, c, t)
+}
+
+// textReplacer replaces the matches of a pattern in the text of
+// the selected elements.
+func textReplacer(s *selector.Selector, re *regexp.Regexp, with string) *selection {
+	m := &selection{selector: s}
+	text := mappers.ReplaceText(xml.Name{}, re, with)
+	text.Match = m.match
+	m.mapper = text
+	return m
+}
@@ -0,0 +1,355 @@
+// Package rules builds processors from declarative rule files.
+//
+// A rule file is a YAML (or JSON) document:
+//
+//	namespaces:
+//	  stix: http://stix.mitre.org/stix-1
+//	prune: true
+//	rules:
+//	  - select: /stix:STIX_Package/stix:Indicators/stix:Indicator
+//	    set-attr: {name: version, value: "2.0"}
+//	  - select: //stix:Title
+//	    rename: stix:Name
+//	  - select: //stix:Information_Source
+//	    delete: true
+//	  - select: //AddressObject:Address_Value
+//	    replace-text: {pattern: "##comma##", with: ","}
+//
+// Every rule consists of a selector expression (see package selector) and
+// exactly one action. Prefixes used in selectors and in the names of
+// rename and set-attr are resolved using the namespaces section first,
+// and the declarations of a processed document next; a namespace not
+// declared in a document is declared with the prefix of the namespaces
+// section. Attribute steps are allowed in set-attr selectors only.
+// The rules are applied in the order they're listed, followed by pruning
+// (disabled by default) and namespace normalization (enabled by default,
+// see normalize-ns).
+package rules
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/PlanitarInc/go-xmlproc"
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	"github.com/PlanitarInc/go-xmlproc/selector"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a parsed and validated rule file.
+type File struct {
+	Namespaces  map[string]string
+	Prune       bool
+	NormalizeNS bool
+	Rules       []*Rule
+}
+
+// Rule is a single transformation of a rule file.
+type Rule struct {
+	// Line is the line number of the rule in the file.
+	Line   int
+	Select string
+
+	// Actions, only one of them is set.
+	SetAttr     *SetAttr
+	Rename      string
+	Delete      bool
+	ReplaceText *ReplaceText
+
+	selector *selector.Selector
+	pattern  *regexp.Regexp
+	name     qname
+	// prefixes maps the namespace URIs to the prefixes of the rule file.
+	prefixes map[string]string
+}
+
+// SetAttr sets an attribute of the selected elements, adding it if missing.
+// An unprefixed name stands for an attribute with no namespace.
+type SetAttr struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// ReplaceText replaces the matches of a regular expression in the text
+// content of the selected elements. The replacement may refer to the
+// submatches, see regexp.Regexp.ReplaceAllString.
+type ReplaceText struct {
+	Pattern string `yaml:"pattern"`
+	With    string `yaml:"with"`
+}
+
+// Error describes a problem of a rule file.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ErrorList is a list of the problems of a rule file.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return "rules: " + strings.Join(msgs, "; ")
+}
+
+// LoadFile reads a rule file and builds a processor from it.
+func LoadFile(name string) (*xmlproc.Processor, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Load reads a rule file and builds a processor from it.
+func Load(r io.Reader) (*xmlproc.Processor, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return xmlproc.NewProcessor(xmlproc.WithMappers(f.Mappers()...)), nil
+}
+
+// Parse parses and validates a rule file.
+// All the problems found are reported as ErrorList.
+func Parse(data []byte) (*File, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("rules: %v", err)
+	}
+
+	p := parser{}
+	f := &File{NormalizeNS: true}
+	if len(doc.Content) > 0 {
+		p.parseFile(f, doc.Content[0])
+	}
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return f, nil
+}
+
+// Mappers returns the mappers implementing the rules of the file.
+func (f *File) Mappers() []xmlproc.Mapper {
+	var mm []xmlproc.Mapper
+	for _, r := range f.Rules {
+		mm = append(mm, r.Mapper())
+	}
+	if f.Prune {
+		mm = append(mm, &mappers.Pruner{})
+	}
+	if f.NormalizeNS {
+		mm = append(mm, &mappers.NSNormalizer{})
+	}
+	return mm
+}
+
+// Compile validates a rule and prepares it for use. The namespaces bind
+// prefixes used in the selector, see selector.Compile.
+// Rules returned by Parse are already compiled.
+func (r *Rule) Compile(namespaces map[string]string) error {
+	actions := 0
+	for _, set := range []bool{r.SetAttr != nil, r.Rename != "", r.Delete, r.ReplaceText != nil} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		return fmt.Errorf("expected exactly one action, got %d", actions)
+	}
+
+	s, err := selector.Compile(r.Select, namespaces)
+	if err != nil {
+		return err
+	}
+	if s.HasAttr() && r.SetAttr == nil {
+		return fmt.Errorf("%s: attribute steps are supported by set-attr only", r.Select)
+	}
+	r.selector = s
+
+	r.prefixes = map[string]string{}
+	for prefix, uri := range namespaces {
+		if p, ok := r.prefixes[uri]; !ok || prefix < p {
+			r.prefixes[uri] = prefix
+		}
+	}
+
+	switch {
+	case r.SetAttr != nil && r.SetAttr.Name == "":
+		return fmt.Errorf("set-attr: missing name")
+	case r.SetAttr != nil:
+		r.name = parseQName(r.SetAttr.Name, namespaces)
+	case r.Rename != "":
+		r.name = parseQName(r.Rename, namespaces)
+	case r.ReplaceText != nil:
+		if r.pattern, err = regexp.Compile(r.ReplaceText.Pattern); err != nil {
+			return fmt.Errorf("replace-text: %v", err)
+		}
+	}
+	return nil
+}
+
+// Mapper returns the mapper implementing a compiled rule.
+func (r *Rule) Mapper() xmlproc.Mapper {
+	switch {
+	case r.SetAttr != nil:
+		return newAttrSetter(r.selector, r.name, r.SetAttr.Value, r.prefixes)
+	case r.Rename != "":
+		return newRenamer(r.selector, r.name, r.prefixes)
+	case r.Delete:
+		return deleter(r.selector)
+	default:
+		return textReplacer(r.selector, r.pattern, r.ReplaceText.With)
+	}
+}
+
+type parser struct {
+	errs ErrorList
+}
+
+func (p *parser) errorf(n *yaml.Node, format string, args ...interface{}) {
+	p.errs = append(p.errs, &Error{Line: n.Line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// decode decodes a node reporting a problem on failure.
+func (p *parser) decode(n *yaml.Node, key string, v interface{}) bool {
+	if err := n.Decode(v); err != nil {
+		p.errorf(n, "%s: invalid value", key)
+		return false
+	}
+	return true
+}
+
+// fields calls fn for every key of a mapping node.
+func (p *parser) fields(n *yaml.Node, fn func(key string, value *yaml.Node)) {
+	if n.Kind != yaml.MappingNode {
+		p.errorf(n, "expected a mapping")
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		fn(n.Content[i].Value, n.Content[i+1])
+	}
+}
+
+func (p *parser) parseFile(f *File, n *yaml.Node) {
+	var rules *yaml.Node
+
+	p.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "namespaces":
+			p.decode(v, key, &f.Namespaces)
+		case "prune":
+			p.decode(v, key, &f.Prune)
+		case "normalize-ns":
+			p.decode(v, key, &f.NormalizeNS)
+		case "rules":
+			rules = v
+		default:
+			p.errorf(v, "unknown field %q", key)
+		}
+	})
+
+	if rules == nil {
+		return
+	}
+	if rules.Kind != yaml.SequenceNode {
+		p.errorf(rules, "rules: expected a list")
+		return
+	}
+	for _, rn := range rules.Content {
+		if r := p.parseRule(rn); r != nil {
+			if err := r.Compile(f.Namespaces); err != nil {
+				p.errorf(rn, "%v", err)
+			}
+			f.Rules = append(f.Rules, r)
+		}
+	}
+}
+
+func (p *parser) parseRule(n *yaml.Node) *Rule {
+	r := &Rule{Line: n.Line}
+	ok := true
+
+	p.fields(n, func(key string, v *yaml.Node) {
+		switch key {
+		case "select":
+			ok = p.decode(v, key, &r.Select) && ok
+		case "set-attr":
+			r.SetAttr = &SetAttr{}
+			ok = p.parseFields(v, key, []field{
+				{"name", &r.SetAttr.Name},
+				{"value", &r.SetAttr.Value},
+			}) && ok
+		case "rename":
+			ok = p.decode(v, key, &r.Rename) && ok
+		case "delete":
+			ok = p.decode(v, key, &r.Delete) && ok
+		case "replace-text":
+			r.ReplaceText = &ReplaceText{}
+			ok = p.parseFields(v, key, []field{
+				{"pattern", &r.ReplaceText.Pattern},
+				{"with", &r.ReplaceText.With},
+			}) && ok
+		default:
+			p.errorf(v, "unknown field %q", key)
+			ok = false
+		}
+	})
+
+	if !ok || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	return r
+}
+
+// field is a string field of an action mapping.
+type field struct {
+	key   string
+	value *string
+}
+
+// parseFields decodes the fields of an action mapping, reporting unknown
+// and missing keys.
+func (p *parser) parseFields(n *yaml.Node, action string, fields []field) bool {
+	if n.Kind != yaml.MappingNode {
+		p.errorf(n, "%s: expected a mapping", action)
+		return false
+	}
+
+	ok := true
+	seen := map[string]bool{}
+	p.fields(n, func(key string, v *yaml.Node) {
+		for _, f := range fields {
+			if f.key == key {
+				seen[key] = true
+				ok = p.decode(v, action+"."+key, f.value) && ok
+				return
+			}
+		}
+		p.errorf(v, "%s: unknown field %q", action, key)
+		ok = false
+	})
+	for _, f := range fields {
+		if !seen[f.key] {
+			p.errorf(n, "%s: missing %s", action, f.key)
+			ok = false
+		}
+	}
+	return ok
+}
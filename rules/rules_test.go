@@ -0,0 +1,165 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const doc = `<s:root xmlns:s="urn:s" xmlns:x="urn:x">
+  <s:title>Title</s:title>
+  <s:item id="1">a##comma##b</s:item>
+  <x:item id="2">c##comma##d</x:item>
+  <s:secret>hidden</s:secret>
+</s:root>`
+
+func TestLoad(t *testing.T) {
+	RegisterTestingT(t)
+
+	p, err := Load(bytes.NewBufferString(`
+namespaces:
+  st: urn:s
+prune: true
+rules:
+  - select: /st:root/st:item
+    set-attr: {name: version, value: "2"}
+  - select: //st:title
+    rename: s:name
+  - select: //secret
+    delete: true
+  - select: item
+    replace-text:
+      pattern: "(\\w)##comma##(\\w)"
+      with: "$2,$1"
+`))
+	Ω(err).ShouldNot(HaveOccurred())
+
+	dst := &bytes.Buffer{}
+	err = p.ProcessStreams(dst, bytes.NewBufferString(doc))
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(dst.String()).Should(Equal(`<s:root xmlns:s="urn:s" xmlns:x="urn:x">
+  <s:name>Title</s:name>
+  <s:item id="1" version="2">b,a</s:item>
+  <x:item id="2">d,c</x:item>
+</s:root>`))
+}
+
+func TestLoadJSON(t *testing.T) {
+	RegisterTestingT(t)
+
+	f, err := Parse([]byte(`{
+  "normalize-ns": false,
+  "rules": [
+    {"select": "/root", "rename": "top"}
+  ]
+}`))
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(f.NormalizeNS).Should(BeFalse())
+	Ω(f.Prune).Should(BeFalse())
+	Ω(f.Rules).Should(HaveLen(1))
+	Ω(f.Rules[0].Line).Should(Equal(4))
+	Ω(f.Rules[0].Rename).Should(Equal("top"))
+	Ω(f.Mappers()).Should(HaveLen(1))
+}
+
+func TestParseErrors(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, err := Parse([]byte(`
+namespaces: [a]
+colour: red
+rules:
+  - select: /a
+  - select: /a
+    rename: b
+    delete: true
+  - select: /a[
+    delete: true
+  - select: /a
+    replace-text: {pattern: "(", with: ""}
+  - select: /a
+    set-attr: {value: x}
+  - select: /a
+    delete: maybe
+  - select: /a
+    unknown: true
+  - just a string
+`))
+	Ω(err).Should(HaveOccurred())
+
+	var errs ErrorList
+	Ω(errors.As(err, &errs)).Should(BeTrue())
+
+	lines := []int{}
+	for _, e := range errs {
+		lines = append(lines, e.Line)
+	}
+	Ω(lines).Should(Equal([]int{2, 3, 5, 6, 9, 11, 14, 16, 18, 19}))
+	Ω(errs[1].Error()).Should(Equal(`line 3: unknown field "colour"`))
+	Ω(errs[2].Error()).Should(Equal(`line 5: expected exactly one action, got 0`))
+	Ω(err.Error()).Should(HavePrefix(`rules: line 2: namespaces: invalid value; line 3: `))
+
+	_, err = Parse([]byte(`
+rules:
+  - select: /a
+    set-attr: {name: s:y, vaule: "3"}
+  - select: /a
+    replace-text:
+      pattern: x
+      with: [y]
+`))
+	Ω(err).Should(MatchError(`rules: line 4: set-attr: unknown field "vaule"; line 4: set-attr: missing value; ` +
+		`line 8: replace-text.with: invalid value`))
+
+	_, err = Parse([]byte("rules: {}"))
+	Ω(err).Should(MatchError(`rules: line 1: rules: expected a list`))
+
+	_, err = Parse([]byte("rules: ["))
+	Ω(err).Should(HaveOccurred())
+
+	f, err := Parse(nil)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(f.Rules).Should(BeEmpty())
+}
+
+func TestRuleNames(t *testing.T) {
+	RegisterTestingT(t)
+
+	p, err := Load(bytes.NewBufferString(`
+namespaces:
+  n: urn:n
+  xsi: http://www.w3.org/2001/XMLSchema-instance
+rules:
+  - select: //s:title
+    rename: n:title
+  - select: //s:item
+    set-attr: {name: type, value: plain}
+  - select: //x:item
+    set-attr: {name: n:type, value: "n"}
+  - select: //s:secret
+    replace-text: {pattern: "hid-den", with: "shown"}
+`))
+	Ω(err).ShouldNot(HaveOccurred())
+
+	src := `<s:root xmlns:s="urn:s" xmlns:x="urn:x" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<s:title>Title</s:title><s:item xsi:type="t">a</s:item><x:item>b</x:item>` +
+		`<s:secret>hid<![CDATA[-den]]></s:secret></s:root>`
+	dst := &bytes.Buffer{}
+	Ω(p.ProcessStreams(dst, bytes.NewBufferString(src))).ShouldNot(HaveOccurred())
+	Ω(dst.String()).Should(Equal(`<s:root xmlns:s="urn:s" xmlns:x="urn:x" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <n:title xmlns:n="urn:n">Title</n:title>
+  <s:item xsi:type="t" type="plain">a</s:item>
+  <x:item n:type="n" xmlns:n="urn:n">b</x:item>
+  <s:secret>shown</s:secret>
+</s:root>`))
+
+	p, err = Load(bytes.NewBufferString(`rules: [{select: //s:title, rename: "u:title"}]`))
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(p.ProcessStreams(&bytes.Buffer{}, bytes.NewBufferString(src))).Should(MatchError(ContainSubstring(`rename: undeclared prefix "u"`)))
+
+	_, err = Parse([]byte(`rules: [{select: //a/@id, delete: true}, {select: //a/@id, rename: b}]`))
+	Ω(err).Should(MatchError(`rules: line 1: //a/@id: attribute steps are supported by set-attr only; ` +
+		`line 1: //a/@id: attribute steps are supported by set-attr only`))
+}
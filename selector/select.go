@@ -0,0 +1,38 @@
+package selector
+
+import (
+	"encoding/xml"
+
+	"github.com/PlanitarInc/go-xmlproc"
+)
+
+// Selected is a mapper forwarding only the tokens selected by a selector
+// to the inner mapper. All other tokens are passed through unchanged.
+// The mapper relies on the context maintained by xmlproc.Processor.
+type Selected struct {
+	Selector *Selector
+	Mapper   xmlproc.Mapper
+}
+
+// Select wraps a mapper so it's called only for the tokens matching
+// the expression. It panics if the expression cannot be parsed.
+func Select(expr string, m xmlproc.Mapper) *Selected {
+	return MustCompile(expr, nil).Select(m)
+}
+
+// Select wraps a mapper so it's called only for the tokens matching
+// the selector.
+func (s *Selector) Select(m xmlproc.Mapper) *Selected {
+	return &Selected{Selector: s, Mapper: m}
+}
+
+func (m *Selected) Map(t xml.Token) (xml.Token, error) {
+	return xmlproc.Single(m.MapContext(&xmlproc.Context{}, t))
+}
+
+func (m *Selected) MapContext(c *xmlproc.Context, t xml.Token) ([]xml.Token, error) {
+	if !m.Selector.MatchToken(c, t) {
+		return []xml.Token{t}, nil
+	}
+	return xmlproc.Apply(m.Mapper, c, t)
+}
@@ -0,0 +1,404 @@
+// Package selector implements a streaming-friendly subset of XPath
+// for targeting tokens processed by xmlproc.
+//
+// The supported syntax:
+//
+//	/a/b        child axis, starting at the root element
+//	//b, a//b   descendant axis
+//	b/c         relative expressions match at any depth, same as //b/c
+//	p:b         name test; the prefix is resolved using the namespace map
+//	            given to Compile, or the declarations of the document
+//	{uri}b      name test with an explicit namespace URI; {}b matches
+//	            names without a namespace
+//	b           local name test, matching any namespace
+//	*, p:*      wildcards
+//	b[2]        position among the siblings matching the name test
+//	b[@x]       attribute existence
+//	b[@x='v']   attribute value equality, != is supported as well
+//	a/b/@x      attribute step, must be the last step of an expression
+//
+// Several predicates of a step must all match.
+package selector
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+)
+
+// Selector is a compiled selector expression.
+type Selector struct {
+	expr  string
+	ns    map[string]string
+	steps []step
+	attr  *nameTest
+}
+
+type step struct {
+	descendant bool
+	name       nameTest
+	preds      []predicate
+}
+
+type nameTest struct {
+	prefix   string
+	space    string
+	hasSpace bool
+	local    string
+}
+
+type predicate struct {
+	position int
+	attr     *nameTest
+	op       string
+	value    string
+}
+
+// Compile parses a selector expression.
+// The namespace map binds prefixes used in the expression to namespace
+// URIs; prefixes not present in the map are resolved against the namespace
+// declarations of a processed document.
+func Compile(expr string, ns map[string]string) (*Selector, error) {
+	p := parser{s: expr}
+	s, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("selector: %s: %v", expr, err)
+	}
+	s.expr = expr
+	s.ns = ns
+	return s, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(expr string, ns map[string]string) *Selector {
+	s, err := Compile(expr, ns)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the source expression of the selector.
+func (s *Selector) String() string {
+	return s.expr
+}
+
+// HasAttr reports whether the selector ends with an attribute step.
+func (s *Selector) HasAttr() bool {
+	return s.attr != nil
+}
+
+// Match reports whether the path of open elements matches the element
+// steps of the selector. The attribute step, if any, is ignored.
+func (s *Selector) Match(path mappers.Path) bool {
+	return s.match(path, 0, 0)
+}
+
+// MatchToken reports whether a token, positioned in a document according
+// to the context, is selected by the selector.
+// Start and end tags are selected when the path including the element
+// itself matches. If the selector ends with an attribute step, only start
+// tags having a matching attribute are selected.
+// Other tokens are selected when the path of their enclosing elements
+// matches, and the selector has no attribute step.
+func (s *Selector) MatchToken(c *mappers.Context, t xml.Token) bool {
+	switch t.(type) {
+	case xml.StartElement:
+		if !s.Match(c.Path) {
+			return false
+		}
+		return s.attr == nil || s.matchAttr(c.Path) != nil
+	default:
+		return s.attr == nil && s.Match(c.Path)
+	}
+}
+
+// MatchAttr returns the attributes of the innermost element in the path
+// selected by the attribute step of the selector.
+// It returns nil if the path doesn't match or there's no attribute step.
+func (s *Selector) MatchAttr(path mappers.Path) []xml.Attr {
+	if s.attr == nil || !s.Match(path) {
+		return nil
+	}
+	return s.matchAttr(path)
+}
+
+func (s *Selector) matchAttr(path mappers.Path) []xml.Attr {
+	var res []xml.Attr
+	for _, a := range path.Top().Attr {
+		if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+			continue
+		}
+		if s.matchName(s.attr, a.Name, path) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func (s *Selector) match(path mappers.Path, i, j int) bool {
+	if i == len(s.steps) {
+		return j == len(path)
+	}
+	if j == len(path) {
+		return false
+	}
+
+	st := &s.steps[i]
+	if !st.descendant {
+		return s.matchStep(st, path[:j+1]) && s.match(path, i+1, j+1)
+	}
+	for k := j; k < len(path); k++ {
+		if s.matchStep(st, path[:k+1]) && s.match(path, i+1, k+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchStep checks a step against the last element of the path.
+func (s *Selector) matchStep(st *step, path mappers.Path) bool {
+	e := path.Top()
+	if !s.matchName(&st.name, e.Name, path) {
+		return false
+	}
+
+	for _, pred := range st.preds {
+		switch {
+		case pred.position > 0:
+			pos := path.Position(func(name xml.Name) bool {
+				return s.matchName(&st.name, name, path)
+			})
+			if pos != pred.position {
+				return false
+			}
+
+		case pred.attr != nil:
+			found := false
+			for _, a := range e.Attr {
+				if a.Name.Space == "xmlns" || !s.matchName(pred.attr, a.Name, path) {
+					continue
+				}
+				switch pred.op {
+				case "":
+					found = true
+				case "=":
+					found = a.Value == pred.value
+				case "!=":
+					found = a.Value != pred.value
+				}
+				if found {
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *Selector) matchName(n *nameTest, name xml.Name, path mappers.Path) bool {
+	if n.local != "*" && n.local != name.Local {
+		return false
+	}
+
+	switch {
+	case n.hasSpace:
+		return n.space == name.Space
+	case n.prefix != "":
+		uri, ok := s.ns[n.prefix]
+		if !ok {
+			uri, ok = path.LookupPrefix(n.prefix)
+		}
+		return ok && uri == name.Space
+	default:
+		return true
+	}
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) parse() (*Selector, error) {
+	s := &Selector{}
+	if p.s == "" {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	descendant := !strings.HasPrefix(p.s, "/")
+	for p.pos < len(p.s) {
+		if p.consume("//") {
+			descendant = true
+		} else if p.consume("/") {
+			// child axis
+		} else if len(s.steps) > 0 {
+			return nil, p.errorf("expected /")
+		}
+
+		if p.consume("@") {
+			n, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			if p.pos != len(p.s) {
+				return nil, p.errorf("attribute step must be the last one")
+			}
+			s.attr = &n
+			break
+		}
+
+		st := step{descendant: descendant}
+		descendant = false
+
+		n, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		st.name = n
+
+		for p.consume("[") {
+			pred, err := p.parsePredicate()
+			if err != nil {
+				return nil, err
+			}
+			st.preds = append(st.preds, pred)
+			if !p.consume("]") {
+				return nil, p.errorf("expected ]")
+			}
+		}
+		s.steps = append(s.steps, st)
+	}
+
+	if len(s.steps) == 0 {
+		return nil, fmt.Errorf("no element steps")
+	}
+	return s, nil
+}
+
+func (p *parser) parseName() (nameTest, error) {
+	n := nameTest{}
+	if p.consume("{") {
+		end := strings.IndexByte(p.s[p.pos:], '}')
+		if end < 0 {
+			return n, p.errorf("expected }")
+		}
+		n.space = p.s[p.pos : p.pos+end]
+		n.hasSpace = true
+		p.pos += end + 1
+	}
+
+	if p.consume("*") {
+		n.local = "*"
+		return n, nil
+	}
+
+	local := p.parseNCName()
+	if local == "" {
+		return n, p.errorf("expected name")
+	}
+	if !n.hasSpace && p.consume(":") {
+		n.prefix = local
+		if p.consume("*") {
+			n.local = "*"
+			return n, nil
+		}
+		if local = p.parseNCName(); local == "" {
+			return n, p.errorf("expected local name")
+		}
+	}
+	n.local = local
+	return n, nil
+}
+
+func (p *parser) parseNCName() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '/' || c == '[' || c == ']' || c == '@' || c == ':' ||
+			c == '=' || c == '!' || c == '{' || c == '*' || c == ' ' {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *parser) parsePredicate() (predicate, error) {
+	pred := predicate{}
+	p.skipSpaces()
+
+	if p.consume("@") {
+		n, err := p.parseName()
+		if err != nil {
+			return pred, err
+		}
+		pred.attr = &n
+		p.skipSpaces()
+
+		if p.consume("!=") {
+			pred.op = "!="
+		} else if p.consume("=") {
+			pred.op = "="
+		} else {
+			return pred, nil
+		}
+
+		p.skipSpaces()
+		if pred.value, err = p.parseLiteral(); err != nil {
+			return pred, err
+		}
+		p.skipSpaces()
+		return pred, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil || n < 1 {
+		return pred, p.errorf("unsupported predicate")
+	}
+	pred.position = n
+	p.skipSpaces()
+	return pred, nil
+}
+
+func (p *parser) parseLiteral() (string, error) {
+	if p.pos == len(p.s) || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+		return "", p.errorf("expected quoted value")
+	}
+	quote := p.s[p.pos]
+	end := strings.IndexByte(p.s[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorf("unterminated value")
+	}
+	v := p.s[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return v, nil
+}
+
+func (p *parser) consume(s string) bool {
+	if strings.HasPrefix(p.s[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
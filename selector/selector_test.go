@@ -0,0 +1,183 @@
+package selector
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/PlanitarInc/go-xmlproc"
+	"github.com/PlanitarInc/go-xmlproc/mappers"
+	. "github.com/onsi/gomega"
+)
+
+func path(names ...xml.Name) mappers.Path {
+	c := mappers.Context{}
+	for _, n := range names {
+		c.Enter(xml.StartElement{Name: n})
+	}
+	return c.Path
+}
+
+func TestCompileErrors(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, expr := range []string{
+		"",
+		"/",
+		"//@id",
+		"a/@id/b",
+		"a[",
+		"a[0]",
+		"a[last()]",
+		"a[@x=v]",
+		"a[@x='v]",
+		"{urn:a",
+		"a/p:",
+	} {
+		_, err := Compile(expr, nil)
+		Ω(err).Should(HaveOccurred(), expr)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	RegisterTestingT(t)
+
+	ns := map[string]string{"p": "urn:p"}
+	a := xml.Name{Space: "urn:p", Local: "a"}
+	b := xml.Name{Space: "urn:p", Local: "b"}
+	c := xml.Name{Local: "c"}
+
+	for _, tc := range []struct {
+		expr  string
+		path  mappers.Path
+		match bool
+	}{
+		{"/a", path(a), true},
+		{"/a", path(a, b), false},
+		{"/a/b", path(a, b), true},
+		{"/b", path(a, b), false},
+		{"b", path(a, b), true},
+		{"//b", path(a, b), true},
+		{"a//c", path(a, b, c), true},
+		{"/a//c", path(a, c), true},
+		{"/a//a", path(a), false},
+		{"/p:a/p:b", path(a, b), true},
+		{"/p:a/q:b", path(a, b), false},
+		{"/{urn:p}a/{}c", path(a, c), true},
+		{"/{}a", path(a), false},
+		{"/p:*/*", path(a, c), true},
+		{"/p:*/p:*", path(a, c), false},
+		{"/a/b[1]", path(a, b), true},
+		{"/a/b[2]", path(a, b), false},
+	} {
+		s := MustCompile(tc.expr, ns)
+		Ω(s.Match(tc.path)).Should(Equal(tc.match), tc.expr)
+	}
+}
+
+func TestMatchPosition(t *testing.T) {
+	RegisterTestingT(t)
+
+	r := xml.Name{Local: "r"}
+	b := xml.Name{Local: "b"}
+	pb := xml.Name{Space: "urn:p", Local: "b"}
+	ns := map[string]string{"p": "urn:p"}
+
+	ctx := mappers.Context{}
+	ctx.Enter(xml.StartElement{Name: r})
+	for _, n := range []xml.Name{b, pb} {
+		ctx.Enter(xml.StartElement{Name: n})
+		ctx.Leave()
+	}
+	ctx.Enter(xml.StartElement{Name: b})
+
+	for _, tc := range []struct {
+		expr  string
+		match bool
+	}{
+		{"/r/b[1]", false},
+		{"/r/b[2]", false},
+		{"/r/b[3]", true},
+		{"/r/{}b[2]", true},
+		{"/r/*[3]", true},
+		{"/r/p:*[1]", false},
+	} {
+		Ω(MustCompile(tc.expr, ns).Match(ctx.Path)).Should(Equal(tc.match), tc.expr)
+	}
+}
+
+func TestMatchPredicates(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := mappers.Context{}
+	ctx.Enter(xml.StartElement{
+		Name: xml.Name{Space: "urn:p", Local: "a"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Space: "xmlns", Local: "p"}, Value: "urn:p"},
+			{Name: xml.Name{Local: "id"}, Value: "1"},
+			{Name: xml.Name{Space: "urn:p", Local: "type"}, Value: "t"},
+		},
+	})
+
+	for _, tc := range []struct {
+		expr  string
+		match bool
+	}{
+		{"/p:a[@id]", true},
+		{"/p:a[@name]", false},
+		{"/p:a[@id='1']", true},
+		{"/p:a[@id = \"2\"]", false},
+		{"/p:a[@id!='2']", true},
+		{"/p:a[@p:type='t'][@id]", true},
+		{"/p:a[@{}type]", false},
+		{"/p:a/@id", true},
+		{"/p:a/@p:type", true},
+		{"/p:a/@name", false},
+		{"/p:a/@*", true},
+	} {
+		s := MustCompile(tc.expr, nil)
+		Ω(s.MatchToken(&ctx, xml.StartElement{})).Should(Equal(tc.match), tc.expr)
+	}
+
+	s := MustCompile("/p:a/@*", nil)
+	Ω(s.MatchAttr(ctx.Path)).Should(HaveLen(2))
+	Ω(s.MatchToken(&ctx, xml.EndElement{})).Should(BeFalse())
+	Ω(s.MatchToken(&ctx, xml.CharData("x"))).Should(BeFalse())
+
+	s = MustCompile("/p:a", nil)
+	Ω(s.MatchAttr(ctx.Path)).Should(BeNil())
+	Ω(s.MatchToken(&ctx, xml.EndElement{})).Should(BeTrue())
+	Ω(s.MatchToken(&ctx, xml.CharData("x"))).Should(BeTrue())
+}
+
+type upper struct{}
+
+func (m upper) Map(t xml.Token) (xml.Token, error) {
+	if start, ok := t.(xml.StartElement); ok {
+		start.Attr = append(start.Attr, xml.Attr{
+			Name:  xml.Name{Local: "selected"},
+			Value: "yes",
+		})
+		return start, nil
+	}
+	return t, nil
+}
+
+func TestSelect(t *testing.T) {
+	RegisterTestingT(t)
+
+	src := `<a xmlns:p="urn:p"><p:b id="1"></p:b><p:b></p:b><c><p:b id="2"></p:b></c></a>`
+	dst := &bytes.Buffer{}
+
+	p := xmlproc.Processor{
+		Mappers: []xmlproc.Mapper{
+			Select("/a/p:b/@id", upper{}),
+			&mappers.NSNormalizer{},
+		},
+	}
+	enc := xml.NewEncoder(dst)
+	err := p.Process(enc, xml.NewDecoder(bytes.NewBufferString(src)))
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(enc.Flush()).ShouldNot(HaveOccurred())
+	Ω(dst.String()).Should(Equal(`<a xmlns:p="urn:p"><p:b id="1" selected="yes"></p:b><p:b></p:b><c><p:b id="2"></p:b></c></a>`))
+}
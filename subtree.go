@@ -0,0 +1,67 @@
+package xmlproc
+
+import "encoding/xml"
+
+// Subtree is a mapper buffering whole elements in order to transform them
+// at once. When Match reports true for a start tag, the element and all its
+// content are collected into a Node, which is passed to Func once the end
+// tag is reached. The tokens of the node returned by Func are emitted in
+// place of the element; if Func returns nil, the element is dropped.
+// Tokens outside of the matched elements are passed through unchanged.
+// Elements nested in a matched element are never matched themselves.
+type Subtree struct {
+	Match func(*Context, xml.StartElement) bool
+	Func  func(*Context, *Node) (*Node, error)
+
+	stack []*Node
+}
+
+func (m *Subtree) Map(t xml.Token) (xml.Token, error) {
+	return Single(m.MapContext(&Context{}, t))
+}
+
+func (m *Subtree) MapContext(c *Context, t xml.Token) ([]xml.Token, error) {
+	switch token := t.(type) {
+	case xml.StartElement:
+		if len(m.stack) == 0 && (m.Match == nil || !m.Match(c, token)) {
+			return []xml.Token{t}, nil
+		}
+
+		n := &Node{Token: token.Copy()}
+		if len(m.stack) > 0 {
+			top := m.stack[len(m.stack)-1]
+			top.Children = append(top.Children, n)
+		}
+		m.stack = append(m.stack, n)
+		return nil, nil
+
+	case xml.EndElement:
+		if len(m.stack) == 0 {
+			return []xml.Token{t}, nil
+		}
+
+		root := m.stack[0]
+		m.stack = m.stack[:len(m.stack)-1]
+		if len(m.stack) > 0 {
+			return nil, nil
+		}
+
+		if m.Func == nil {
+			return root.Tokens(), nil
+		}
+		res, err := m.Func(c, root)
+		if err != nil || res == nil {
+			return nil, err
+		}
+		return res.Tokens(), nil
+
+	default:
+		if len(m.stack) == 0 {
+			return []xml.Token{t}, nil
+		}
+
+		top := m.stack[len(m.stack)-1]
+		top.Children = append(top.Children, &Node{Token: xml.CopyToken(t)})
+		return nil, nil
+	}
+}